@@ -0,0 +1,97 @@
+package announcement
+
+// ABI encoded calls of the announcer contracts (see: `IECPDKSAP_Announcer`, `IERC5564Announcer`):
+//
+//	calldata: | selector: keccak256(signature)[:4] | head: one word per argument | tails |
+//	          the static arguments (address, uint256) are left-padded words in the head, the `bytes` arguments
+//	          are the offsets (from the head start) of their tails: the length word followed by the bytes
+//	          right-padded to a multiple of 32
+
+import (
+	"fmt"
+
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/utils"
+)
+
+const (
+	// ECPDKSAP announcer: announces and forwards the call's ether to the stealth address
+	SendEthViaProxySignature = "sendEthViaProxy(address,bytes,bytes)"
+
+	// ECPDKSAP announcer: announces an ether transfer made directly to the stealth address
+	EthSentWithoutProxySignature = "ethSentWithoutProxy(bytes,bytes)"
+
+	// Canonical ERC-5564 announcer
+	AnnounceSignature = "announce(uint256,address,bytes,bytes)"
+)
+
+// Selector returns the function selector: the first 4 bytes of keccak256 of the signature
+func Selector(signature string) []byte {
+
+	return utils.Keccak256([]byte(signature))[:4]
+}
+
+// SendEthViaProxyCalldata encodes `sendEthViaProxy(stealthAddress, ephemeralPubKey, metadata)`,
+// the ether to send is the call's value
+func SendEthViaProxyCalldata(stealthAddress string, ephemeralPubKey []byte, metadata []byte) ([]byte, error) {
+
+	stealthAddressWord, err := utils.AddressWord(stealthAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return abiCall(SendEthViaProxySignature, stealthAddressWord, ephemeralPubKey, metadata), nil
+}
+
+// EthSentWithoutProxyCalldata encodes `ethSentWithoutProxy(ephemeralPubKey, metadata)`
+func EthSentWithoutProxyCalldata(ephemeralPubKey []byte, metadata []byte) []byte {
+	return abiCall(EthSentWithoutProxySignature, ephemeralPubKey, metadata)
+}
+
+// AnnounceCalldata encodes `announce(3327, stealthAddress, ephemeralPubKey, metadata)` (see: `meta_address.SchemeId`),
+// an empty stealth address is encoded as the zero address (as `ethSentWithoutProxy` does)
+func AnnounceCalldata(stealthAddress string, ephemeralPubKey []byte, metadata []byte) ([]byte, error) {
+
+	var stealthAddressWord [32]byte
+
+	if stealthAddress != "" {
+		var err error
+		if stealthAddressWord, err = utils.AddressWord(stealthAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	var schemeIdWord [32]byte
+	copy(schemeIdWord[:], utils.UintWord(meta_address.SchemeId))
+
+	return abiCall(AnnounceSignature, schemeIdWord, stealthAddressWord, ephemeralPubKey, metadata), nil
+}
+
+// abiCall encodes the call of the function: the static arguments are passed as words ([32]byte),
+// the `bytes` arguments as []byte
+func abiCall(signature string, args ...any) []byte {
+
+	head := make([]byte, 0, 32*len(args))
+	var tails []byte
+
+	for _, arg := range args {
+		switch arg := arg.(type) {
+
+		case [32]byte:
+			head = append(head, arg[:]...)
+
+		case []byte:
+			offset := uint64(32*len(args) + len(tails))
+			head = append(head, utils.UintWord(offset)...)
+
+			tails = append(tails, utils.UintWord(uint64(len(arg)))...)
+			tails = append(tails, arg...)
+			tails = append(tails, make([]byte, (32-len(arg)%32)%32)...)
+
+		default:
+			panic(fmt.Sprintf("unsupported ABI argument type %T", arg))
+		}
+	}
+
+	return append(append(Selector(signature), head...), tails...)
+}
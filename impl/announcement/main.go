@@ -0,0 +1,124 @@
+package announcement
+
+// Announcement encoding (ERC-5564 `ephemeralPubKey` and `metadata` fields, see: `IECPDKSAP_Announcer`):
+//
+//	ephemeralPubKey: sender's public key R, compressed BN254 G1 point (32 bytes, big-endian X coordinate
+//	                 with the two most significant bits used as flags for the Y coordinate / infinity)
+//	metadata:        view tag bytes first (`ViewTagScheme.Length()` bytes, see: `view_tags`),
+//	                 followed by optional sender defined data
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+
+	"ecpdksap-go/utils"
+	"ecpdksap-go/validation"
+	"ecpdksap-go/view_tags"
+)
+
+// EncodeR serializes the sender's public key R into its compressed form
+func EncodeR(R *BN254.G1Affine) []byte {
+
+	R_asBytes := R.Bytes()
+
+	return R_asBytes[:]
+}
+
+// DecodeR parses the compressed form of the sender's public key R
+// note: every rejected R is a `validation.InvalidPointError` (a wrong length: `validation.ErrInvalidEncoding`),
+// so the scan skips the announcement instead of stopping
+func DecodeR(ephemeralPubKey []byte) (R BN254.G1Affine, err error) {
+
+	if len(ephemeralPubKey) != BN254.SizeOfG1AffineCompressed {
+		return R, invalidEncoding(fmt.Errorf("ephemeral public key must be %d bytes long, got %d", BN254.SizeOfG1AffineCompressed, len(ephemeralPubKey)))
+	}
+
+	//note: rejects the points at infinity too (see: `validation.BN254_G1Point`)
+	if R, err = utils.BN254_G1PointFromBytes(ephemeralPubKey); err != nil {
+		return R, fmt.Errorf("invalid ephemeral public key: %w", err)
+	}
+
+	return R, nil
+}
+
+// EncodeMetadata places the (hex encoded) view tag at the beginning of the metadata
+func EncodeMetadata(viewTag string, viewTagVersion string) ([]byte, error) {
+
+	scheme, err := view_tags.Get(viewTagVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = view_tags.Validate(scheme, viewTag); err != nil {
+		return nil, err
+	}
+
+	return hex.DecodeString(viewTag)
+}
+
+// DecodeMetadata extracts the (hex encoded) view tag from the metadata
+func DecodeMetadata(metadata []byte, viewTagVersion string) (viewTag string, err error) {
+
+	scheme, err := view_tags.Get(viewTagVersion)
+	if err != nil {
+		return "", err
+	}
+	nBytes := scheme.Length()
+
+	if len(metadata) < nBytes {
+		return "", fmt.Errorf("metadata too short for the %s view tag: %d bytes", viewTagVersion, len(metadata))
+	}
+
+	return hex.EncodeToString(metadata[:nBytes]), nil
+}
+
+// Encode serializes the announcement (R and view tag) into the `ephemeralPubKey` and `metadata` fields
+func Encode(R *BN254.G1Affine, viewTag string, viewTagVersion string) (ephemeralPubKey []byte, metadata []byte, err error) {
+
+	metadata, err = EncodeMetadata(viewTag, viewTagVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return EncodeR(R), metadata, nil
+}
+
+// Decode parses the `ephemeralPubKey` and `metadata` fields of the announcement
+func Decode(ephemeralPubKey []byte, metadata []byte, viewTagVersion string) (R BN254.G1Affine, viewTag string, err error) {
+
+	if R, err = DecodeR(ephemeralPubKey); err != nil {
+		return R, "", err
+	}
+
+	viewTag, err = DecodeMetadata(metadata, viewTagVersion)
+
+	return R, viewTag, err
+}
+
+// ParseR parses the textual form of R: either the (optionally 0x prefixed) hex encoded compressed point
+// or the legacy "XAffineCoord.YAffineCoord" decimal form
+// note: a malformed string (e.g. not hex or decimal) is a plain parse error, a well-formed encoding of
+// an invalid point a `validation.InvalidPointError` (see: `DecodeR`)
+func ParseR(in string) (R BN254.G1Affine, err error) {
+
+	if strings.IndexByte(in, '.') != -1 {
+		if R, err = utils.BN254_G1PointFromXY(in); err != nil && !validation.IsInvalidPoint(err) {
+			return R, fmt.Errorf("invalid ephemeral public key: %w", err)
+		}
+		return R, err
+	}
+
+	ephemeralPubKey, err := hex.DecodeString(strings.TrimPrefix(in, "0x"))
+	if err != nil {
+		return R, fmt.Errorf("ephemeral public key is not a hex string: %w", err)
+	}
+
+	return DecodeR(ephemeralPubKey)
+}
+
+func invalidEncoding(err error) error {
+	return &validation.InvalidPointError{Group: validation.Group_BN254_G1, Err: fmt.Errorf("%w: %v", validation.ErrInvalidEncoding, err)}
+}
@@ -1,6 +1,7 @@
 package bn254_constant_recipient_keys_bench
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"math/big"
@@ -17,6 +18,7 @@ import (
 	ecpdksap_v2 "ecpdksap-go/versions/v2"
 
 	"ecpdksap-go/utils"
+	"ecpdksap-go/view_tags"
 )
 
 func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[string]time.Duration {
@@ -43,7 +45,6 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 
 		neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix := EC.PrecomputationForFixedScalarMultiplication(v_asBigIntPtr)
 		var table [15]EC.G1Jac
-		var a_El, b_El *EC_fp.Element
 		b_asBigInt := new(big.Int)
 
 		//random data generation: Rj
@@ -63,10 +64,15 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 			cm.Rj = new(EC.G1Jac)
 			cm.Rj.FromAffine(&Rj_asAff)
 			cm.Rj_asAffArr = []EC.G1Affine{Rj_asAff}
-			cm.ViewTagTwoBytes = uint16(rndGen.Uint32() % 65536)
-			cm.ViewTagSingleByte = uint8(rndGen.Uint32() % 256)
-			cm.ViewTagSecondByte = uint8(rndGen.Uint32() % 256)
-			cm.viewTag11Nibbles.SetUint64(rndGen.Uint64() >> 20)
+
+			//note: the announced view tags are those of a random point (i.e. the announcements are not the recipient's)
+			_, _, _, announced := _EC_GenerateG1KeyPair(rndGen)
+			cm.ViewTags = map[string][]byte{}
+			for _, viewTagVersion := range view_tags.List() {
+				viewTagScheme, _ := view_tags.GetBytesComputer(viewTagVersion)
+				cm.ViewTags[viewTagVersion] = make([]byte, viewTagScheme.Length())
+				viewTagScheme.ComputeBytes(&announced, cm.ViewTags[viewTagVersion])
+			}
 
 			combinedMeta = append(combinedMeta, cm)
 		}
@@ -86,22 +92,23 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 		var vR EC.G1Jac
 		var vR_asAff EC.G1Affine
 
-		hasher := sha256.New()
+		var viewTagScheme view_tags.BytesComputer
+		var viewTag []byte
+		var a_El, b_El *EC_fp.Element
 
 		precomputedQLines := [][2][66]EC.LineEvaluationAff{EC.PrecomputeLines(K2_EC_asAffArr[0])}
 
 		//protocol: V0 and viewTag: V0-1byte
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-1byte")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			if hasher.Sum(compressed[:])[0] != cm.ViewTagSingleByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -113,19 +120,16 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 		durations["v0.v0-1byte"] += b.Elapsed()
 
 		//protocol: V0 and viewTag: V0-2bytes
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-2bytes")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
-
-			hash := hasher.Sum(compressed[:])
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			if hash[0] != cm.ViewTagSingleByte || hash[1] != cm.ViewTagSecondByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -137,15 +141,18 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 		durations["v0.v0-2bytes"] += b.Elapsed()
 
 		//protocol: V0 and viewTag: V1-1byte
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v1-1byte")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
+			//note: the X coordinate view tag reads only X, Y is computed for the matching announcements
 			a_El, b_El = vR_asAff.FromJacobianCoordX(&vR)
+			viewTagScheme.ComputeBytes(&vR_asAff, viewTag)
 
-			if vR_asAff.X.Bytes()[0] != cm.ViewTagSingleByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -158,24 +165,17 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 
 		durations["v0.v1-1byte"] += b.Elapsed()
 
-		var viewTag big.Int
-
 		//protocol: V0 and viewTag: V0-11nibbles
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-11nibbles")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			hash := hasher.Sum(compressed[:])[:6]
-			hash[0] >>= 4
-			viewTag.SetBytes(hash)
-
-			if viewTag.Cmp(&cm.viewTag11Nibbles) != 0 {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -197,17 +197,16 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 
 		precomputedQLines[0] = EC.PrecomputeLines(K_asArray[0])
 
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-1byte")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			if hasher.Sum(compressed[:])[0] != cm.ViewTagSingleByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -217,19 +216,16 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 		durations["v1.v0-1byte"] += b.Elapsed()
 
 		//protocol: V1 and viewTag: V0-2bytes
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-2bytes")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
-
-			hash := hasher.Sum(compressed[:])
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			if hash[0] != cm.ViewTagSingleByte || hash[1] != cm.ViewTagSecondByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -240,15 +236,18 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 
 		//protocol: V1 and viewTag: V1-1byte
 
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v1-1byte")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
+			//note: the X coordinate view tag reads only X, Y is computed for the matching announcements
 			a_El, b_El = vR_asAff.FromJacobianCoordX(&vR)
+			viewTagScheme.ComputeBytes(&vR_asAff, viewTag)
 
-			if vR_asAff.X.Bytes()[0] != cm.ViewTagSingleByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -276,21 +275,16 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 		K_SECP256k1_AffPtr.FromJacobian(K_SECP256k1_JacPtr)
 
 		//protocol: V1 and viewTag: V0-11nibbles
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-11nibbles")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
-
-			hash := hasher.Sum(compressed[:])[:6]
-			hash[0] >>= 4
-			viewTag.SetBytes(hash)
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			if viewTag.Cmp(&cm.viewTag11Nibbles) != 0 {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -302,46 +296,37 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 		//protocol: V2 and viewTag: v0-1byte
 		precomputedQLines[0] = EC.PrecomputeLines(g2Aff_asArray[0])
 
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-1byte")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
-
-			hash := hasher.Sum(compressed[:])
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			if hash[0] != cm.ViewTagSingleByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
-			if hasher.Sum(compressed[:])[0] == cm.ViewTagSingleByte {
-
-				S, _ := EC.PairFixedQ([]EC.G1Affine{vR_asAff}, precomputedQLines)
+			S, _ := EC.PairFixedQ([]EC.G1Affine{vR_asAff}, precomputedQLines)
 
-				Pv2_asJac.ScalarMultiplication(K_SECP256k1_JacPtr, S.C0.B0.A0.BigInt(b_asBigInt))
-			}
+			Pv2_asJac.ScalarMultiplication(K_SECP256k1_JacPtr, S.C0.B0.A0.BigInt(b_asBigInt))
 		}
 
 		durations["v2.v0-1byte"] += b.Elapsed()
 
 		//protocol: V2 and viewTag: v0-2bytes
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-2bytes")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
-			hasher.Reset()
-
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			hash := hasher.Sum(compressed[:])
-
-			if hash[0] != cm.ViewTagSingleByte || hash[1] != cm.ViewTagSecondByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -353,15 +338,18 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 		durations["v2.v0-2bytes"] += b.Elapsed()
 
 		//protocol: V2 and viewTag: v1-1byte
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v1-1byte")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
+			//note: the X coordinate view tag reads only X, Y is computed for the matching announcements
 			a_El, b_El = vR_asAff.FromJacobianCoordX(&vR)
+			viewTagScheme.ComputeBytes(&vR_asAff, viewTag)
 
-			if vR_asAff.X.Bytes()[0] != cm.ViewTagSingleByte {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -374,20 +362,17 @@ func Run(b *testing.B, sampleSize int, nRepetitions int, randomSeed int) map[str
 
 		durations["v2.v1-1byte"] += b.Elapsed()
 
-		//protocol: V2 and viewTag: v1-1byte
+		//protocol: V2 and viewTag: v0-11nibbles
+		viewTagScheme, viewTag = _SelectViewTagScheme(combinedMeta, "v0-11nibbles")
 		b.ResetTimer()
 
 		for _, cm := range combinedMeta {
 
 			vR.FixedScalarMultiplication(cm.Rj, &table, neg, k1, k2, tableElementNeeded, hiWordIndex, useMatrix)
 
-			compressed := vR_asAff.FromJacobian(&vR).Bytes()
+			viewTagScheme.ComputeBytes(vR_asAff.FromJacobian(&vR), viewTag)
 
-			hash := hasher.Sum(compressed[:])[:6]
-			hash[0] >>= 4
-			viewTag.SetBytes(hash)
-
-			if viewTag.Cmp(&cm.viewTag11Nibbles) != 0 {
+			if !bytes.Equal(viewTag, cm.ViewTag) {
 				continue
 			}
 
@@ -472,10 +457,28 @@ func _EC_HashG1AffPoint(pt *EC.G1Affine) *big.Int {
 }
 
 type _CombinedMeta struct {
-	Rj                *EC.G1Jac
-	Rj_asAffArr       []EC.G1Affine
-	ViewTagTwoBytes   uint16
-	ViewTagSingleByte uint8
-	ViewTagSecondByte uint8
-	viewTag11Nibbles  big.Int
+	Rj          *EC.G1Jac
+	Rj_asAffArr []EC.G1Affine
+
+	// view tag version -> announced view tag
+	ViewTags map[string][]byte
+
+	// announced view tag of the benchmarked version (see: `_SelectViewTagScheme`)
+	ViewTag []byte
+}
+
+// _SelectViewTagScheme resolves the view tag scheme and the announced view tags before the timed loops,
+// which then only compare raw bytes
+func _SelectViewTagScheme(combinedMeta []*_CombinedMeta, viewTagVersion string) (view_tags.BytesComputer, []byte) {
+
+	viewTagScheme, err := view_tags.GetBytesComputer(viewTagVersion)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, cm := range combinedMeta {
+		cm.ViewTag = cm.ViewTags[viewTagVersion]
+	}
+
+	return viewTagScheme, make([]byte, viewTagScheme.Length())
 }
@@ -7,6 +7,7 @@ import (
 	"ecpdksap-go/gen_example"
 	"ecpdksap-go/recipient"
 	"ecpdksap-go/sender"
+	"ecpdksap-go/view_tags"
 	"testing"
 )
 
@@ -15,7 +16,7 @@ func Benchmark_ThroughCLI(b *testing.B) {
 	sampleSize := "1000"
 
 	protocolVersions := []string{"v0", "v1", "v2"}
-	viewTagVersions := []string{"none", "v0-1byte", "v0-2bytes", "v1-1byte"}
+	viewTagVersions := view_tags.List()
 
 	for _, pVersion := range protocolVersions {
 
@@ -26,10 +27,12 @@ func Benchmark_ThroughCLI(b *testing.B) {
 			sendParams, recipientParams := gen_example.GenerateExample(pVersion, vtVersion, sampleSize)
 
 			jsonBytes, _ := json.MarshalIndent(sendParams, "", " ")
-			sender.Send(string(jsonBytes))
+			sender.SendFromJSON(string(jsonBytes))
 
 			jsonBytes, _ = json.MarshalIndent(recipientParams, "", " ")
-			recipient.Scan(string(jsonBytes))
+			_, stats, _ := recipient.ScanFromJSON(string(jsonBytes))
+			fmt.Println("ECPDKSAP ::: version:", pVersion, "ViewTagVersion:", vtVersion, "; time:", stats.Duration)
+			fmt.Println(stats)
 
 			fmt.Println("")
 		}
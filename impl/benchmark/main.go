@@ -42,6 +42,11 @@ func RunBench(kind string, rndSeed int) {
 		bn254_crk.Run(b, 5_000, 10, rndSeed)
 		bn254_crk.Run(b, 80_000, 10, rndSeed)
 
+	} else if kind == "scan-scaling" {
+
+		RunScanScaling("v0-1byte", []int{80_000, 1_000_000}, 1, rndSeed)
+		RunScanScaling("v0-11nibbles", []int{80_000, 1_000_000}, 1, rndSeed)
+
 	} else if kind == "all-curves" {
 
 		_Benchmark_Curves(b, 5_000, 10, rndSeed)
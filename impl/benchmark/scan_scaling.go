@@ -0,0 +1,79 @@
+package benchmark
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"time"
+
+	EC "github.com/consensys/gnark-crypto/ecc/bn254"
+
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/versions"
+	"ecpdksap-go/view_tags"
+)
+
+// RunScanScaling measures the throughput of `recipient.Scanner.Scan` (v2) for a growing number of workers (1, 2, 4, ..., nCPU)
+func RunScanScaling(viewTagVersion string, sampleSizes []int, nRepetitions int, rndSeed int) {
+
+	rndGen := rand.New(rand.NewSource(int64(rndSeed)))
+
+	p, _ := versions.Get("v2")
+	keys, err := p.GenerateMetaAddress()
+	if err != nil {
+		panic(err)
+	}
+
+	s, err := recipient.NewScanner(keys, viewTagVersion)
+	if err != nil {
+		panic(err)
+	}
+
+	viewTagScheme, _ := view_tags.Get(viewTagVersion)
+	viewTag_asBytes := make([]byte, viewTagScheme.Length())
+
+	var workerCounts []int
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		workerCounts = append(workerCounts, n)
+	}
+	workerCounts = append(workerCounts, runtime.NumCPU())
+
+	for _, sampleSize := range sampleSizes {
+
+		Rs := make([]EC.G1Affine, sampleSize)
+		viewTags := make([]string, sampleSize)
+
+		for i := range Rs {
+			_, _, _, Rs[i] = _EC_GenerateG1KeyPair(rndGen)
+			rndGen.Read(viewTag_asBytes)
+			viewTags[i] = hex.EncodeToString(viewTag_asBytes)
+		}
+
+		fmt.Println("--------- Scan scaling: v2,", viewTagVersion, "sampleSize:", sampleSize, "nCPU:", runtime.NumCPU())
+
+		var sequentialDuration time.Duration
+
+		for _, nWorkers := range workerCounts {
+
+			s.Parallelism = nWorkers
+
+			var stats recipient.Stats
+			for rep := 0; rep < nRepetitions; rep++ {
+				if _, err := s.Scan(Rs, viewTags, &stats); err != nil {
+					panic(err)
+				}
+			}
+
+			duration := stats.Duration / time.Duration(nRepetitions)
+			if nWorkers == 1 {
+				sequentialDuration = duration
+			}
+
+			fmt.Printf("workers: %3d ; time: %14v ; speedup: %5.2fx ; throughput: %10.0f announcements/s\n",
+				nWorkers, duration, float64(sequentialDuration)/float64(duration), float64(sampleSize)/duration.Seconds())
+		}
+
+		fmt.Println()
+	}
+}
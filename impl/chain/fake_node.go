@@ -0,0 +1,314 @@
+package chain
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+
+	"ecpdksap-go/events"
+	"ecpdksap-go/utils"
+)
+
+// FakeNode is an in-process stand-in for an Ethereum node (e.g. served by `httptest.NewServer`) answering
+// the `Client` methods from the recorded logs and the set balances and nonces, the sent transactions are only stored
+//
+// The blocks are empty apart from the logs, their hashes are derived from the number and the fork (see: `Reorg`),
+// so the block hashes of the recorded logs are replaced by the node's ones
+type FakeNode struct {
+	mu sync.Mutex
+
+	blockNumber uint64
+	logs        []events.Log
+	forks       []fakeFork
+	balances    map[string]*big.Int
+	nonces      map[string]uint64
+	sentTxs     [][]byte
+
+	// Called after each answered request, e.g. to reorganize the chain between two calls of a client
+	AfterRequest func(method string)
+}
+
+// fakeFork: the blocks from `fromBlock` on belong to the fork `id` (until a later fork)
+type fakeFork struct {
+	fromBlock uint64
+	id        uint64
+}
+
+func NewFakeNode() *FakeNode {
+	return &FakeNode{balances: map[string]*big.Int{}, nonces: map[string]uint64{}}
+}
+
+// AddLogs appends the logs (ordered by their position), the block number is raised to the last log's block
+func (n *FakeNode) AddLogs(logs ...events.Log) error {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i := range logs {
+		blockNumber, err := utils.ParseQuantity(logs[i].BlockNumber)
+		if err != nil {
+			return fmt.Errorf("log %d: invalid block number: %w", i, err)
+		}
+
+		n.blockNumber = max(n.blockNumber, blockNumber)
+		n.logs = append(n.logs, logs[i])
+	}
+
+	return nil
+}
+
+func (n *FakeNode) SetBlockNumber(blockNumber uint64) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.blockNumber = blockNumber
+}
+
+// Reorg replaces the blocks from `fromBlock` on by a new (so far empty) fork: their logs are dropped and the
+// block number is lowered to `fromBlock - 1`, the new fork grows by `AddLogs` and `SetBlockNumber`
+func (n *FakeNode) Reorg(fromBlock uint64) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	logs := n.logs[:0]
+	for _, log := range n.logs {
+		if blockNumber, _ := utils.ParseQuantity(log.BlockNumber); blockNumber < fromBlock {
+			logs = append(logs, log)
+		}
+	}
+	n.logs = logs
+
+	var lastId uint64
+	forks := n.forks[:0]
+	for _, f := range n.forks {
+		lastId = max(lastId, f.id)
+		if f.fromBlock < fromBlock {
+			forks = append(forks, f)
+		}
+	}
+	n.forks = append(forks, fakeFork{fromBlock: fromBlock, id: lastId + 1})
+
+	if fromBlock > 0 {
+		n.blockNumber = min(n.blockNumber, fromBlock-1)
+	} else {
+		n.blockNumber = 0
+	}
+}
+
+func (n *FakeNode) SetBalance(address string, wei *big.Int) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.balances[strings.ToLower(address)] = new(big.Int).Set(wei)
+}
+
+func (n *FakeNode) SetNonce(address string, nonce uint64) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nonces[strings.ToLower(address)] = nonce
+}
+
+// SentTransactions returns the raw transactions received by `eth_sendRawTransaction`
+func (n *FakeNode) SentTransactions() [][]byte {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([][]byte(nil), n.sentTxs...)
+}
+
+func (n *FakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	var req struct {
+		Id     uint64            `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	resp := rpcResponse{JSONRPC: "2.0"}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error = &RPCError{Code: -32700, Message: "parse error"}
+	} else {
+		resp.Id = req.Id
+
+		result, rpcErr := n.handle(req.Method, req.Params)
+		if rpcErr != nil {
+			resp.Error = rpcErr
+		} else {
+			resp.Result, _ = json.Marshal(result)
+		}
+
+		if n.AfterRequest != nil {
+			n.AfterRequest(req.Method)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (n *FakeNode) handle(method string, params []json.RawMessage) (any, *RPCError) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch method {
+
+	case "eth_blockNumber":
+		return utils.FormatQuantity(n.blockNumber), nil
+
+	case "eth_getBlockByNumber":
+		var tag string
+		if len(params) != 2 || json.Unmarshal(params[0], &tag) != nil {
+			return nil, invalidParams("expected a block number and a bool")
+		}
+
+		number, err := n.blockTag(tag)
+		if err != nil {
+			return nil, invalidParams("invalid block number")
+		}
+		if number > n.blockNumber {
+			return nil, nil
+		}
+
+		parentHash := "0x" + strings.Repeat("0", 64)
+		if number > 0 {
+			parentHash = n.blockHash(number - 1)
+		}
+
+		return headerData{Number: utils.FormatQuantity(number), Hash: n.blockHash(number), ParentHash: parentHash}, nil
+
+	case "eth_getLogs":
+		var filter filterData
+		if len(params) != 1 || json.Unmarshal(params[0], &filter) != nil {
+			return nil, invalidParams("expected a filter object")
+		}
+
+		fromBlock, err1 := n.blockTag(filter.FromBlock)
+		toBlock, err2 := n.blockTag(filter.ToBlock)
+		if err1 != nil || err2 != nil {
+			return nil, invalidParams("invalid block range")
+		}
+
+		logs := []events.Log{}
+		for i := range n.logs {
+			if blockNumber, _ := utils.ParseQuantity(n.logs[i].BlockNumber); blockNumber >= fromBlock && blockNumber <= toBlock && filter.matches(&n.logs[i]) {
+				log := n.logs[i]
+				log.BlockHash = n.blockHash(blockNumber)
+				logs = append(logs, log)
+			}
+		}
+
+		return logs, nil
+
+	case "eth_getBalance":
+		var address string
+		if len(params) != 2 || json.Unmarshal(params[0], &address) != nil {
+			return nil, invalidParams("expected an address and a block tag")
+		}
+
+		balance, ok := n.balances[strings.ToLower(address)]
+		if !ok {
+			balance = new(big.Int)
+		}
+
+		return "0x" + balance.Text(16), nil
+
+	case "eth_getTransactionCount":
+		var address string
+		if len(params) != 2 || json.Unmarshal(params[0], &address) != nil {
+			return nil, invalidParams("expected an address and a block tag")
+		}
+
+		return utils.FormatQuantity(n.nonces[strings.ToLower(address)]), nil
+
+	case "eth_sendRawTransaction":
+		var rawTx_asHex string
+		if len(params) != 1 || json.Unmarshal(params[0], &rawTx_asHex) != nil {
+			return nil, invalidParams("expected a raw transaction")
+		}
+
+		rawTx, err := hex.DecodeString(strings.TrimPrefix(rawTx_asHex, "0x"))
+		if err != nil || len(rawTx) == 0 {
+			return nil, invalidParams("raw transaction is not a hex string")
+		}
+
+		n.sentTxs = append(n.sentTxs, rawTx)
+
+		//note: the transaction hash is keccak256 of the raw transaction
+		return "0x" + hex.EncodeToString(utils.Keccak256(rawTx)), nil
+	}
+
+	return nil, &RPCError{Code: -32601, Message: fmt.Sprintf("method %s not found", method)}
+}
+
+func (n *FakeNode) blockTag(tag string) (uint64, error) {
+
+	switch tag {
+	case "", "latest":
+		return n.blockNumber, nil
+	case "earliest":
+		return 0, nil
+	}
+
+	return utils.ParseQuantity(tag)
+}
+
+func (n *FakeNode) blockHash(number uint64) string {
+
+	var forkId uint64
+	for _, f := range n.forks {
+		if f.fromBlock <= number {
+			forkId = f.id
+		}
+	}
+
+	hash := utils.Keccak256([]byte("FakeNode block"), binary.BigEndian.AppendUint64(nil, number), binary.BigEndian.AppendUint64(nil, forkId))
+
+	return "0x" + hex.EncodeToString(hash)
+}
+
+// matches reports whether the log passes the address and topic filters
+func (filter *filterData) matches(log *events.Log) bool {
+
+	if len(filter.Address) != 0 && !containsFold(filter.Address, log.Address) {
+		return false
+	}
+
+	for i, accepted := range filter.Topics {
+		if len(accepted) == 0 {
+			continue
+		}
+		if i >= len(log.Topics) || !containsFold(accepted, log.Topics[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func invalidParams(message string) *RPCError {
+	return &RPCError{Code: -32602, Message: "invalid params: " + message}
+}
@@ -0,0 +1,216 @@
+package chain
+
+// Minimal Ethereum JSON-RPC connector: only the methods needed to find (eth_getLogs, eth_blockNumber,
+// eth_getBlockByNumber), check (eth_getBalance) and sweep (eth_getTransactionCount, eth_sendRawTransaction)
+// the stealth transfers.
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+
+	"ecpdksap-go/events"
+	"ecpdksap-go/utils"
+)
+
+// Client is implemented by `RPCClient` (and can be by any other connector)
+type Client interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+
+	// HeaderByNumber returns the header of the block, nil if there is no such block (yet)
+	HeaderByNumber(ctx context.Context, number uint64) (*Header, error)
+
+	GetLogs(ctx context.Context, query LogQuery) ([]events.Log, error)
+
+	// GetBalance returns the balance (in wei) at the latest block
+	GetBalance(ctx context.Context, address string) (*big.Int, error)
+
+	// GetTransactionCount returns the nonce of the address' next transaction (the pending ones included)
+	GetTransactionCount(ctx context.Context, address string) (uint64, error)
+
+	// SendRawTransaction broadcasts the signed (serialized) transaction and returns its hash
+	SendRawTransaction(ctx context.Context, rawTx []byte) (txHash string, err error)
+}
+
+// LogQuery is the `eth_getLogs` filter: the block range (inclusive), the contract addresses and the topics
+// (`Topics[i]` lists the accepted values of the i-th topic, empty: any)
+type LogQuery struct {
+	FromBlock uint64
+	ToBlock   uint64
+	Addresses []string
+	Topics    [][]string
+}
+
+// Header contains the block fields needed to follow the chain (and detect reorgs)
+type Header struct {
+	Number     uint64
+	Hash       string
+	ParentHash string
+}
+
+// RPCError is the error object of the JSON-RPC response
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
+// RPCClient calls the JSON-RPC methods over HTTP
+type RPCClient struct {
+	URL        string
+	HTTPClient *http.Client
+
+	id atomic.Uint64
+}
+
+func NewRPCClient(url string) *RPCClient {
+	return &RPCClient{URL: url, HTTPClient: http.DefaultClient}
+}
+
+func (c *RPCClient) BlockNumber(ctx context.Context) (uint64, error) {
+
+	var res string
+	if err := c.call(ctx, "eth_blockNumber", []any{}, &res); err != nil {
+		return 0, err
+	}
+
+	return utils.ParseQuantity(res)
+}
+
+func (c *RPCClient) HeaderByNumber(ctx context.Context, number uint64) (*Header, error) {
+
+	var res *headerData
+	if err := c.call(ctx, "eth_getBlockByNumber", []any{utils.FormatQuantity(number), false}, &res); err != nil || res == nil {
+		return nil, err
+	}
+
+	blockNumber, err := utils.ParseQuantity(res.Number)
+	if err != nil {
+		return nil, fmt.Errorf("eth_getBlockByNumber: invalid block number: %w", err)
+	}
+	if blockNumber != number {
+		return nil, fmt.Errorf("eth_getBlockByNumber: got block %d instead of %d", blockNumber, number)
+	}
+
+	return &Header{Number: blockNumber, Hash: res.Hash, ParentHash: res.ParentHash}, nil
+}
+
+func (c *RPCClient) GetLogs(ctx context.Context, query LogQuery) (logs []events.Log, err error) {
+
+	err = c.call(ctx, "eth_getLogs", []any{toFilterData(&query)}, &logs)
+
+	return logs, err
+}
+
+func (c *RPCClient) GetBalance(ctx context.Context, address string) (*big.Int, error) {
+
+	var res string
+	if err := c.call(ctx, "eth_getBalance", []any{address, "latest"}, &res); err != nil {
+		return nil, err
+	}
+
+	return utils.ParseBigQuantity(res)
+}
+
+func (c *RPCClient) GetTransactionCount(ctx context.Context, address string) (uint64, error) {
+
+	var res string
+	if err := c.call(ctx, "eth_getTransactionCount", []any{address, "pending"}, &res); err != nil {
+		return 0, err
+	}
+
+	return utils.ParseQuantity(res)
+}
+
+func (c *RPCClient) SendRawTransaction(ctx context.Context, rawTx []byte) (txHash string, err error) {
+
+	err = c.call(ctx, "eth_sendRawTransaction", []any{"0x" + hex.EncodeToString(rawTx)}, &txHash)
+
+	return txHash, err
+}
+
+func (c *RPCClient) call(ctx context.Context, method string, params []any, result any) error {
+
+	reqBytes, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", Id: c.id.Add(1), Method: method, Params: params})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(reqBytes))
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP status %s", method, resp.Status)
+	}
+
+	var rpcResp rpcResponse
+	if err = json.Unmarshal(respBytes, &rpcResp); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("%s: %w", method, rpcResp.Error)
+	}
+
+	if err = json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("%s: invalid result: %w", method, err)
+	}
+
+	return nil
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	Id      uint64 `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// headerData is the JSON form of `Header` (the other block fields are ignored)
+type headerData struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+}
+
+// filterData is the JSON form of `LogQuery`
+type filterData struct {
+	FromBlock string     `json:"fromBlock"`
+	ToBlock   string     `json:"toBlock"`
+	Address   []string   `json:"address,omitempty"`
+	Topics    [][]string `json:"topics,omitempty"`
+}
+
+func toFilterData(query *LogQuery) filterData {
+	return filterData{
+		FromBlock: utils.FormatQuantity(query.FromBlock),
+		ToBlock:   utils.FormatQuantity(query.ToBlock),
+		Address:   query.Addresses,
+		Topics:    query.Topics,
+	}
+}
@@ -0,0 +1,99 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"ecpdksap-go/events"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/recipient"
+)
+
+// Canonical ERC-5564 announcer (see: `Constants.ERC5564_ANNOUNCER_ADDRESS`)
+const ERC5564AnnouncerAddress = "0x55649E01B5Df198D18D95b5cc5051630cfD45564"
+
+// Number of blocks per `eth_getLogs` request (the providers limit the range)
+const DefaultMaxBlockRange = 5_000
+
+// SchemeIdTopic is the `schemeId` topic of the ECPDKSAP announcements
+var SchemeIdTopic = fmt.Sprintf("0x%064x", meta_address.SchemeId)
+
+// AnnouncementsQuery is the filter of the ECPDKSAP announcements emitted by the announcer in the block range
+func AnnouncementsQuery(announcer string, fromBlock uint64, toBlock uint64) LogQuery {
+	return LogQuery{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		Addresses: []string{announcer},
+		Topics:    [][]string{{events.AnnouncementTopic}, {SchemeIdTopic}},
+	}
+}
+
+// BatchEnd returns the last block of the batch starting at `fromBlock`: at most `maxBlockRange` blocks
+// (0: no limit) and not beyond `toBlock`
+func BatchEnd(fromBlock uint64, toBlock uint64, maxBlockRange uint64) uint64 {
+
+	//note: `maxBlockRange - 1` as both ends are included
+	if maxBlockRange != 0 && toBlock-fromBlock >= maxBlockRange {
+		return fromBlock + maxBlockRange - 1
+	}
+
+	return toBlock
+}
+
+// AnnouncementReader reads the announcements of the block range from the chain, `MaxBlockRange` blocks at a time
+type AnnouncementReader struct {
+	Client         Client
+	Announcer      string
+	ViewTagVersion string
+	MaxBlockRange  uint64
+
+	ctx     context.Context
+	next    uint64
+	toBlock uint64
+	logs    *events.LogReader
+}
+
+func NewAnnouncementReader(ctx context.Context, client Client, announcer string, fromBlock uint64, toBlock uint64, viewTagVersion string) *AnnouncementReader {
+	return &AnnouncementReader{
+		Client:         client,
+		Announcer:      announcer,
+		ViewTagVersion: viewTagVersion,
+		MaxBlockRange:  DefaultMaxBlockRange,
+
+		ctx:     ctx,
+		next:    fromBlock,
+		toBlock: toBlock,
+		logs:    events.NewLogReader(nil, viewTagVersion),
+	}
+}
+
+func (r *AnnouncementReader) Next() (recipient.Announcement, error) {
+
+	for {
+		a, err := r.logs.Next()
+		if err != io.EOF {
+			return a, err
+		}
+
+		if r.next > r.toBlock {
+			return a, io.EOF
+		}
+
+		toBlock := BatchEnd(r.next, r.toBlock, r.MaxBlockRange)
+
+		logs, err := r.Client.GetLogs(r.ctx, AnnouncementsQuery(r.Announcer, r.next, toBlock))
+		if err != nil {
+			return a, fmt.Errorf("error fetching the logs of blocks %d-%d: %w", r.next, toBlock, err)
+		}
+
+		r.logs = events.NewLogReader(logs, r.ViewTagVersion)
+		r.next = toBlock + 1
+	}
+}
+
+// ScanRange scans the announcements of the block range (see: `recipient.Scanner.ScanStream`),
+// `Match.BlockNumber` and `Match.LogIndex` locate the announcement's log
+func ScanRange(ctx context.Context, client Client, s *recipient.Scanner, announcer string, fromBlock uint64, toBlock uint64, emit func(recipient.Match) error, stats *recipient.Stats) error {
+	return s.ScanStream(NewAnnouncementReader(ctx, client, announcer, fromBlock, toBlock, s.ViewTagVersion), emit, stats)
+}
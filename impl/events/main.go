@@ -0,0 +1,234 @@
+package events
+
+// Decoding of the ERC-5564 `Announcement` event logs (emitted by `ECPDKSAP_Announcer` and the canonical
+// ERC-5564 announcer, see: `IERC5564Announcer`):
+//
+//	event Announcement(uint256 indexed schemeId, address indexed stealthAddress, address indexed caller,
+//	                   bytes ephemeralPubKey, bytes metadata)
+//
+//	topics: | keccak256(signature) | schemeId | stealthAddress | caller |  (32 bytes each)
+//	data:   ABI encoded (ephemeralPubKey, metadata): two head words with the offsets of the tails,
+//	        each tail is the length word followed by the bytes right-padded to a multiple of 32
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/validation"
+)
+
+const AnnouncementSignature = "Announcement(uint256,address,address,bytes,bytes)"
+
+// AnnouncementTopic is the first topic of the `Announcement` logs: keccak256(AnnouncementSignature)
+var AnnouncementTopic = "0x" + hex.EncodeToString(utils.Keccak256([]byte(AnnouncementSignature)))
+
+// ErrNotAnnouncement is returned for the logs of other events
+var ErrNotAnnouncement = errors.New("not an Announcement log")
+
+// Log is an Ethereum log entry as returned by the `eth_getLogs` JSON-RPC method
+type Log struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash,omitempty"`
+	TransactionHash  string   `json:"transactionHash,omitempty"`
+	TransactionIndex string   `json:"transactionIndex,omitempty"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed,omitempty"`
+}
+
+// AnnouncementEvent is the decoded `Announcement` log
+type AnnouncementEvent struct {
+	SchemeId       *big.Int
+	StealthAddress string
+	Caller         string
+
+	EphemeralPubKey []byte
+	Metadata        []byte
+
+	BlockNumber     uint64
+	LogIndex        uint
+	BlockHash       string
+	TransactionHash string
+}
+
+// DecodeLog decodes the `Announcement` log (any scheme id), `ErrNotAnnouncement` for the logs of other events
+// note: on a data error, `SchemeId` (and the addresses) are already set from the topics
+func DecodeLog(log *Log) (ev AnnouncementEvent, err error) {
+
+	if len(log.Topics) == 0 || !strings.EqualFold(log.Topics[0], AnnouncementTopic) {
+		return ev, ErrNotAnnouncement
+	}
+	if len(log.Topics) != 4 {
+		return ev, fmt.Errorf("Announcement log must have 4 topics, got %d", len(log.Topics))
+	}
+
+	var topics [3][]byte
+	for i := range topics {
+		if topics[i], err = utils.DecodeWord(log.Topics[i+1]); err != nil {
+			return ev, fmt.Errorf("invalid topic %d: %w", i+1, err)
+		}
+	}
+
+	ev.SchemeId = new(big.Int).SetBytes(topics[0])
+	if ev.StealthAddress, err = utils.WordToAddress(topics[1]); err != nil {
+		return ev, fmt.Errorf("invalid stealth address: %w", err)
+	}
+	if ev.Caller, err = utils.WordToAddress(topics[2]); err != nil {
+		return ev, fmt.Errorf("invalid caller: %w", err)
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(log.Data, "0x"))
+	if err != nil {
+		return ev, fmt.Errorf("log data is not a hex string: %w", err)
+	}
+
+	if ev.EphemeralPubKey, err = abiBytesAt(data, 0); err != nil {
+		return ev, fmt.Errorf("invalid ephemeral public key: %w", err)
+	}
+	if ev.Metadata, err = abiBytesAt(data, 1); err != nil {
+		return ev, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	if ev.BlockNumber, err = utils.ParseQuantity(log.BlockNumber); err != nil {
+		return ev, fmt.Errorf("invalid block number: %w", err)
+	}
+	logIndex, err := utils.ParseQuantity(log.LogIndex)
+	if err != nil {
+		return ev, fmt.Errorf("invalid log index: %w", err)
+	}
+	ev.LogIndex = uint(logIndex)
+
+	ev.BlockHash = log.BlockHash
+	ev.TransactionHash = log.TransactionHash
+
+	return ev, nil
+}
+
+// IsECPDKSAP reports whether the announcement uses the ECPDKSAP scheme (id 3327, see: `meta_address.SchemeId`)
+func (ev *AnnouncementEvent) IsECPDKSAP() bool {
+	return ev.SchemeId.IsUint64() && ev.SchemeId.Uint64() == meta_address.SchemeId
+}
+
+// ToAnnouncement decodes R and the view tag (see: `announcement.Decode`)
+// note: anyone can announce, a malformed R or metadata is reported as `validation.InvalidPointError`
+func (ev *AnnouncementEvent) ToAnnouncement(viewTagVersion string) (a recipient.Announcement, err error) {
+
+	a.BlockNumber = ev.BlockNumber
+	a.LogIndex = ev.LogIndex
+
+	if a.R, a.ViewTag, err = announcement.Decode(ev.EphemeralPubKey, ev.Metadata, viewTagVersion); err != nil && !validation.IsInvalidPoint(err) {
+		err = &validation.InvalidPointError{Group: validation.Group_BN254_G1, Err: fmt.Errorf("%w: %v", validation.ErrInvalidEncoding, err)}
+	}
+
+	return a, err
+}
+
+// LogReader feeds the ECPDKSAP announcements of the logs to the scanner (see: `recipient.Scanner.ScanStream`),
+// the logs of other events and schemes, and the removed (reorged) logs are skipped
+// note: anyone can announce, a malformed `Announcement` log (e.g. truncated data) is returned as
+// `validation.InvalidPointError`, so the scan skips it and counts it in `Stats.NInvalid`
+type LogReader struct {
+	logs           []Log
+	viewTagVersion string
+	next           int
+
+	// Number of skipped logs
+	NSkipped int
+}
+
+func NewLogReader(logs []Log, viewTagVersion string) *LogReader {
+	return &LogReader{logs: logs, viewTagVersion: viewTagVersion}
+}
+
+func (r *LogReader) Next() (a recipient.Announcement, err error) {
+
+	for ; r.next < len(r.logs); r.next++ {
+
+		log := &r.logs[r.next]
+		if log.Removed {
+			r.NSkipped++
+			continue
+		}
+
+		//note: the scheme id is known once the topics are decoded, even if the data is malformed
+		ev, err := DecodeLog(log)
+		if errors.Is(err, ErrNotAnnouncement) || (ev.SchemeId != nil && !ev.IsECPDKSAP()) {
+			r.NSkipped++
+			continue
+		}
+
+		r.next++
+
+		if err != nil {
+			a.BlockNumber, _ = utils.ParseQuantity(log.BlockNumber)
+			logIndex, _ := utils.ParseQuantity(log.LogIndex)
+			a.LogIndex = uint(logIndex)
+
+			return a, &validation.InvalidPointError{Group: validation.Group_BN254_G1, Err: fmt.Errorf("%w: log %d: %v", validation.ErrInvalidEncoding, r.next-1, err)}
+		}
+
+		return ev.ToAnnouncement(r.viewTagVersion)
+	}
+
+	return a, io.EOF
+}
+
+// ScanLogs checks the ECPDKSAP announcements of the logs, `Match.Index` is the position among them
+// (`Match.BlockNumber` and `Match.LogIndex` locate the log)
+// note: `stats` is optional (can be nil)
+func ScanLogs(s *recipient.Scanner, logs []Log, stats *recipient.Stats) (matches []recipient.Match, err error) {
+
+	err = s.ScanStream(NewLogReader(logs, s.ViewTagVersion), func(m recipient.Match) error {
+		matches = append(matches, m)
+		return nil
+	}, stats)
+
+	return matches, err
+}
+
+// abiBytesAt decodes the dynamic `bytes` value of the `idx`-th head word
+func abiBytesAt(data []byte, idx uint64) ([]byte, error) {
+
+	offset, err := abiUint(data, 32*idx)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := abiUint(data, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	//note: no overflow, both the offset and the length are bounded by len(data)
+	start := offset + 32
+	if start+length > uint64(len(data)) {
+		return nil, fmt.Errorf("bytes out of bounds: offset %d, length %d, data %d bytes", offset, length, len(data))
+	}
+
+	return data[start : start+length], nil
+}
+
+// abiUint reads the (big-endian) 32-byte word at `offset` as an integer fitting into the data
+func abiUint(data []byte, offset uint64) (uint64, error) {
+
+	if offset > uint64(len(data)) || offset+32 > uint64(len(data)) {
+		return 0, fmt.Errorf("word out of bounds: offset %d, data %d bytes", offset, len(data))
+	}
+
+	word := new(big.Int).SetBytes(data[offset : offset+32])
+	if !word.IsUint64() || word.Uint64() > uint64(len(data)) {
+		return 0, fmt.Errorf("value %s out of bounds", word)
+	}
+
+	return word.Uint64(), nil
+}
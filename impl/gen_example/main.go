@@ -6,7 +6,12 @@ import (
 	"os"
 	"strconv"
 
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/protocol"
 	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+	"ecpdksap-go/view_tags"
 )
 
 func GenerateExample(version string, viewTagVersion string, sampleSizeStr string) (sendParams SendParams, recipientParams RecipientParams) {
@@ -17,25 +22,52 @@ func GenerateExample(version string, viewTagVersion string, sampleSizeStr string
 	var K_asString string
 	var kBytes []byte
 
+	meta := protocol.MetaAddress{Version: version, V: V}
+
 	if version == "v0" || version == "v1" {
 		k, K, _ := utils.BN254_GenG2KeyPair()
-		K_asString = K.X.String() + "." + K.Y.String()
+		K_asString = utils.BN254_G2PointToString(&K)
 		kBytes = k.Marshal()
+		meta.K_G2 = K
 	}
 
 	if version == "v2" {
 		k, K := utils.SECP256k_Gen1G1KeyPair()
 		K_asString = K.X.String() + "." + K.Y.String()
 		kBytes = k.Marshal()
+		meta.K_SECP256k1 = K
 	}
 
+	metaAddress, _ := meta_address.EncodeString(&meta)
+
 	V_asString := V.X.String() + "." + V.Y.String()
-	R_asString := R.X.String() + "." + R.Y.String()
+	R_asString := hex.EncodeToString(announcement.EncodeR(&R))
+
+	viewTagScheme, err := view_tags.Get(viewTagVersion)
+	if err != nil {
+		panic(err)
+	}
+
+	p, err := versions.Get(version)
+	if err != nil {
+		panic(err)
+	}
 
-	var viewTag string
+	stealth, err := p.DeriveStealth(&r, &meta)
+	if err != nil {
+		panic(err)
+	}
+	viewTag := viewTagScheme.Compute(&stealth.SharedPoint)
 
-	tmp := utils.BN254_MulG1PointandElement(&V, &r)
-	viewTag = utils.ComputeViewTag(viewTagVersion, &tmp)
+	//note: the recipient's side, should match the sender's
+	keys, err := p.KeysFromPrivate(kBytes, v.Marshal())
+	if err != nil {
+		panic(err)
+	}
+	stealth_Recipient, err := p.CheckAnnouncement(&keys, &R, nil)
+	if err != nil {
+		panic(err)
+	}
 
 	metaInfo := MetaDbg{
 		PK_k: hex.EncodeToString(kBytes),
@@ -46,16 +78,21 @@ func GenerateExample(version string, viewTagVersion string, sampleSizeStr string
 		V: V_asString,
 		R: R_asString,
 
+		MetaAddress: metaAddress,
+
 		ViewTag:        viewTag,
 		ViewTagVersion: viewTagVersion,
 		Version:        version,
 
-		P_Sender:    "TODO",
-		P_Recipient: "TODO",
+		P_Sender:    hex.EncodeToString(stealth.PubKeyBytes(p.SpendingKeyGroup())),
+		P_Recipient: hex.EncodeToString(stealth_Recipient.PubKeyBytes(p.SpendingKeyGroup())),
+
+		StealthAddress: stealth.Address,
 	}
 
 	sendParams = SendParams{
 		PK_r:           metaInfo.PK_r,
+		MetaAddress:    metaInfo.MetaAddress,
 		K:              metaInfo.K,
 		V:              metaInfo.V,
 		Version:        version,
@@ -63,7 +100,7 @@ func GenerateExample(version string, viewTagVersion string, sampleSizeStr string
 	}
 
 	sampleSize, _ := strconv.Atoi(sampleSizeStr)
-	Rs, viewTags := utils.GenRandomRsAndViewTags(sampleSize - 1, viewTagVersion)
+	Rs, viewTags := GenRandomRsAndViewTags(sampleSize-1, viewTagScheme)
 	Rs = append(Rs, metaInfo.R)
 	viewTags = append(viewTags, metaInfo.ViewTag)
 
@@ -90,6 +127,22 @@ func GenerateExample(version string, viewTagVersion string, sampleSizeStr string
 	return
 }
 
+func GenRandomRsAndViewTags(len int, viewTagScheme view_tags.ViewTagScheme) (Rs []string, VTags []string) {
+
+	for i := 0; i < len; i++ {
+		r, R, _ := utils.BN254_GenG1KeyPair()
+
+		tmp := utils.BN254_MulG1PointandElement(&R, &r)
+		vTag := viewTagScheme.Compute(&tmp)
+
+		Rs = append(Rs, hex.EncodeToString(announcement.EncodeR(&R)))
+
+		VTags = append(VTags, vTag)
+	}
+
+	return Rs, VTags
+}
+
 type MetaDbg struct {
 	PK_k string `json:"k"`
 	PK_v string `json:"v"`
@@ -99,19 +152,25 @@ type MetaDbg struct {
 	V string
 	R string
 
+	MetaAddress string
+
 	P_Sender string
 	ViewTag  string
 
 	P_Recipient string
 
+	// v2: Ethereum address to send the ETH to
+	StealthAddress string `json:",omitempty"`
+
 	Version        string
 	ViewTagVersion string
 }
 
 type SendParams struct {
-	PK_r string `json:"r"`
-	K    string
-	V    string
+	PK_r        string `json:"r"`
+	MetaAddress string
+	K           string
+	V           string
 
 	Version        string
 	ViewTagVersion string
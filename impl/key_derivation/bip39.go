@@ -0,0 +1,63 @@
+package key_derivation
+
+// BIP-39 mnemonic check (see: https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki): every word is
+// an 11-bit index into the English word list, the concatenated indexes are the entropy (ENT bits) followed by
+// the checksum, the first ENT/32 bits of sha256(entropy)
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Official English word list (sha256: 2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda)
+//
+//go:embed bip39_english.txt
+var bip39EnglishWordList string
+
+var bip39English = func() map[string]int64 {
+
+	indexes := map[string]int64{}
+	for i, word := range strings.Fields(bip39EnglishWordList) {
+		indexes[word] = int64(i)
+	}
+
+	return indexes
+}()
+
+// BIP39EnglishWordList returns the words of the English word list (in the order of their indexes)
+func BIP39EnglishWordList() []string {
+	return strings.Fields(bip39EnglishWordList)
+}
+
+// checkMnemonic checks the words against the English word list and the checksum,
+// so that a mistyped mnemonic is not turned into a different (valid looking) wallet
+func checkMnemonic(words []string) error {
+
+	bits := new(big.Int)
+
+	for i, word := range words {
+		index, ok := bip39English[word]
+		if !ok {
+			return fmt.Errorf("mnemonic word %d (%q) is not in the BIP-39 English word list", i+1, word)
+		}
+		bits.Lsh(bits, 11).Or(bits, big.NewInt(index))
+	}
+
+	nChecksumBits := uint(len(words)) * 11 / 33
+	nEntropyBytes := int(nChecksumBits) * 4
+
+	checksum := new(big.Int).And(bits, big.NewInt(1<<nChecksumBits-1))
+
+	entropy := make([]byte, nEntropyBytes)
+	new(big.Int).Rsh(bits, nChecksumBits).FillBytes(entropy)
+
+	hash := sha256.Sum256(entropy)
+	if checksum.Uint64() != uint64(hash[0]>>(8-nChecksumBits)) {
+		return fmt.Errorf("invalid mnemonic checksum")
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package key_derivation
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+	"golang.org/x/crypto/pbkdf2"
+
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/versions"
+)
+
+// Deterministic derivation of the recipient's keys from a seed.
+//
+// Every key has its own path:
+//
+//	m/3327'/<protocol version number>'/<account>'/<key index>
+//
+// where the key index is `0` for the spending key `k` and `1` for the viewing key `v`.
+// The key is obtained via hash-to-field (RFC 9380, `expand_message_xmd` with SHA-256)
+// of `msg = len(seed) (2 bytes, big-endian) || seed || path` into the scalar field of the
+// key's group, using a per-field domain separation tag (see: `DST_BN254_Fr`, `DST_SECP256k1_Fr`).
+//
+// The same seed thus gives unrelated keys for every protocol version, account and key type.
+
+const Purpose = 3327
+
+const (
+	KeyIndex_Spending = 0
+	KeyIndex_Viewing  = 1
+)
+
+const DST_BN254_Fr = "ECPDKSAP-KEYDERIV-V01-BN254-Fr_XMD:SHA-256_"
+const DST_SECP256k1_Fr = "ECPDKSAP-KEYDERIV-V01-SECP256K1-Fr_XMD:SHA-256_"
+
+const MinSeedLength = 16
+
+// DeriveKeys derives the recipient's keys (and meta address) for the given protocol version and account
+func DeriveKeys(version string, seed []byte, account uint32) (keys protocol.Keys, err error) {
+
+	p, err := versions.Get(version)
+	if err != nil {
+		return keys, err
+	}
+
+	if len(seed) < MinSeedLength || len(seed) > 0xffff {
+		return keys, fmt.Errorf("invalid seed length: %d bytes (min. %d)", len(seed), MinSeedLength)
+	}
+
+	kPath, err := DerivationPath(version, account, KeyIndex_Spending)
+	if err != nil {
+		return keys, err
+	}
+	vPath, _ := DerivationPath(version, account, KeyIndex_Viewing)
+
+	var kBytes []byte
+	if p.SpendingKeyGroup() == protocol.SpendingKeyGroup_BN254_G2 {
+		k, err := hashToBN254Fr(seed, kPath)
+		if err != nil {
+			return keys, err
+		}
+		kBytes = k.Marshal()
+	} else {
+		k, err := hashToSECP256k1Fr(seed, kPath)
+		if err != nil {
+			return keys, err
+		}
+		kBytes = k.Marshal()
+	}
+
+	v, err := hashToBN254Fr(seed, vPath)
+	if err != nil {
+		return keys, err
+	}
+
+	return p.KeysFromPrivate(kBytes, v.Marshal())
+}
+
+// DerivationPath returns the path of the key with `keyIndex` (see: `KeyIndex_Spending`, `KeyIndex_Viewing`)
+func DerivationPath(version string, account uint32, keyIndex uint32) (string, error) {
+
+	versionNumber, err := strconv.ParseUint(strings.TrimPrefix(version, "v"), 10, 8)
+	if err != nil || !strings.HasPrefix(version, "v") {
+		return "", fmt.Errorf("protocol version %q can not be used in a derivation path", version)
+	}
+
+	if account >= 1<<31 {
+		return "", fmt.Errorf("account index %d out of range", account)
+	}
+
+	if keyIndex != KeyIndex_Spending && keyIndex != KeyIndex_Viewing {
+		return "", fmt.Errorf("unknown key index %d", keyIndex)
+	}
+
+	return fmt.Sprintf("m/%d'/%d'/%d'/%d", Purpose, versionNumber, account, keyIndex), nil
+}
+
+// SeedFromMnemonic computes the BIP-39 seed: PBKDF2-HMAC-SHA512(mnemonic, "mnemonic" || passphrase, 2048 iter.)
+//
+// NOTE: the mnemonic must be made of words of the BIP-39 English word list with a valid checksum and,
+// as no NFKD normalization is done, only ASCII passphrases are accepted
+func SeedFromMnemonic(mnemonic string, passphrase string) ([]byte, error) {
+
+	words := strings.Fields(mnemonic)
+
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, fmt.Errorf("invalid mnemonic length: %d words", len(words))
+	}
+
+	if err := checkMnemonic(words); err != nil {
+		return nil, err
+	}
+
+	normalized := strings.Join(words, " ")
+
+	if !isASCII(normalized) || !isASCII(passphrase) {
+		return nil, fmt.Errorf("only ASCII mnemonics and passphrases are supported")
+	}
+
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
+
+func hashToBN254Fr(seed []byte, path string) (*BN254_fr.Element, error) {
+
+	res, err := BN254_fr.Hash(message(seed, path), []byte(DST_BN254_Fr), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if res[0].IsZero() {
+		return nil, fmt.Errorf("derived zero key for %s", path)
+	}
+
+	return &res[0], nil
+}
+
+func hashToSECP256k1Fr(seed []byte, path string) (*SECP256K1_fr.Element, error) {
+
+	res, err := SECP256K1_fr.Hash(message(seed, path), []byte(DST_SECP256k1_Fr), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if res[0].IsZero() {
+		return nil, fmt.Errorf("derived zero key for %s", path)
+	}
+
+	return &res[0], nil
+}
+
+func message(seed []byte, path string) []byte {
+
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(seed)))
+	msg = append(msg, seed...)
+
+	return append(msg, path...)
+}
+
+func isASCII(s string) bool {
+
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,82 @@
+package keygen
+
+import (
+	"encoding/hex"
+
+	"ecpdksap-go/key_derivation"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/versions"
+)
+
+// GenerateKeys generates random (crypto/rand) recipient's keys for the given protocol version
+func GenerateKeys(version string) (keys protocol.Keys, out KeysOutputData, err error) {
+
+	p, err := versions.Get(version)
+	if err != nil {
+		return keys, out, err
+	}
+
+	keys, err = p.GenerateMetaAddress()
+	if err != nil {
+		return keys, out, err
+	}
+
+	out, err = ToOutputData(p, &keys)
+
+	return keys, out, err
+}
+
+// DeriveKeys deterministically derives the recipient's keys from the seed (see: `key_derivation.DeriveKeys`)
+func DeriveKeys(version string, seed []byte, account uint32) (keys protocol.Keys, out KeysOutputData, err error) {
+
+	p, err := versions.Get(version)
+	if err != nil {
+		return keys, out, err
+	}
+
+	keys, err = key_derivation.DeriveKeys(version, seed, account)
+	if err != nil {
+		return keys, out, err
+	}
+
+	out, err = ToOutputData(p, &keys)
+
+	return keys, out, err
+}
+
+// ToOutputData serializes the recipient's keys (private keys as hex, public keys as the encoded meta address)
+func ToOutputData(p protocol.Protocol, keys *protocol.Keys) (KeysOutputData, error) {
+
+	metaAddress, err := meta_address.EncodeString(&keys.Meta)
+	if err != nil {
+		return KeysOutputData{}, err
+	}
+
+	var kBytes []byte
+	if p.SpendingKeyGroup() == protocol.SpendingKeyGroup_BN254_G2 {
+		kBytes = keys.PK_k_BN254.Marshal()
+	} else {
+		kBytes = keys.PK_k_SECP256k1.Marshal()
+	}
+
+	return KeysOutputData{
+		PK_k:        hex.EncodeToString(kBytes),
+		PK_v:        hex.EncodeToString(keys.PK_v.Marshal()),
+		MetaAddress: metaAddress,
+		Version:     p.Version(),
+	}, nil
+}
+
+type KeysOutputData struct {
+	// Recipient's private spending key
+	PK_k string `json:"k"`
+
+	// Recipient's private viewing key
+	PK_v string `json:"v"`
+
+	// Recipient's encoded meta address (to be shared with senders / registered on-chain)
+	MetaAddress string
+
+	Version string
+}
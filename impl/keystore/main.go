@@ -0,0 +1,372 @@
+package keystore
+
+// Encrypted keystore for the recipient's keys (modeled after the Ethereum V3 keystore):
+//
+//   - the private keys are serialized as JSON ({"k": hex, "v": hex}, watch-only: {"v": hex}),
+//   - a 32-byte key is derived from the passphrase using scrypt,
+//   - the serialized keys are encrypted using AES-256-GCM, authenticating the meta address as well.
+//
+// The meta address (public K and V) is stored in plaintext, so that it can be read without the passphrase.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+
+	"ecpdksap-go/keygen"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+const FormatVersion = 1
+
+const PassphraseEnvVar = "ECPDKSAP_PASSPHRASE"
+
+var ErrDecrypt = errors.New("could not decrypt keystore with the given passphrase")
+
+type ScryptParams struct {
+	N int
+	R int
+	P int
+}
+
+// StandardScryptParams should be used for keystores stored on disk
+var StandardScryptParams = ScryptParams{N: 1 << 18, R: 8, P: 1}
+
+// LightScryptParams trades security for speed (e.g. tests, low-memory devices)
+var LightScryptParams = ScryptParams{N: 1 << 12, R: 8, P: 6}
+
+// Check rejects the parameters costing more than `StandardScryptParams`: N and r (memory: 128·N·r bytes) above
+// the standard ones or N·r·p (time) above the standard one; the keystore file is not trusted to bound the decryption
+func (params ScryptParams) Check() error {
+
+	standard := StandardScryptParams
+
+	if params.N <= 1 || params.R <= 0 || params.P <= 0 || params.N > standard.N || params.R > standard.R ||
+		params.P > standard.N*standard.R*standard.P/(params.N*params.R) {
+		return fmt.Errorf("scrypt parameters N=%d, r=%d, p=%d exceed the standard ones (N=%d, r=%d, p=%d)", params.N, params.R, params.P, standard.N, standard.R, standard.P)
+	}
+
+	return nil
+}
+
+// Encrypt creates a keystore holding both the private spending (k) and viewing (v) keys
+func Encrypt(keys *protocol.Keys, passphrase string, params ScryptParams) ([]byte, error) {
+	return encrypt(keys, false, passphrase, params)
+}
+
+// EncryptWatchOnly creates a keystore holding only the private viewing key (v) along with the public K
+func EncryptWatchOnly(keys *protocol.Keys, passphrase string, params ScryptParams) ([]byte, error) {
+	return encrypt(keys, true, passphrase, params)
+}
+
+func encrypt(keys *protocol.Keys, watchOnly bool, passphrase string, params ScryptParams) ([]byte, error) {
+
+	p, err := versions.Get(keys.Meta.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	keysData, err := keygen.ToOutputData(p, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := privateKeysData{PK_v: keysData.PK_v}
+	if !watchOnly {
+		plaintext.PK_k = keysData.PK_k
+	}
+	plaintextBytes, _ := json.Marshal(plaintext)
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %w", err)
+	}
+
+	if err := params.Check(); err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %w", err)
+	}
+
+	aead, err := newAEAD(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	ks := KeystoreData{
+		Version:         FormatVersion,
+		Id:              newUUID(),
+		ProtocolVersion: keys.Meta.Version,
+		MetaAddress:     keysData.MetaAddress,
+		WatchOnly:       watchOnly,
+		Crypto: CryptoData{
+			Cipher:       "aes-256-gcm",
+			CipherParams: CipherParamsData{Nonce: hex.EncodeToString(nonce)},
+			KDF:          "scrypt",
+			KDFParams: KDFParamsData{
+				N:     params.N,
+				R:     params.R,
+				P:     params.P,
+				DKLen: 32,
+				Salt:  hex.EncodeToString(salt),
+			},
+		},
+	}
+
+	ciphertext := aead.Seal(nil, nonce, plaintextBytes, ks.additionalData())
+	ks.Crypto.Ciphertext = hex.EncodeToString(ciphertext)
+
+	return json.MarshalIndent(ks, "", " ")
+}
+
+// Decrypt recovers the recipient's keys from the keystore
+// note: for watch-only keystores the private spending key is left unset
+func Decrypt(keystoreJSON []byte, passphrase string) (keys protocol.Keys, watchOnly bool, err error) {
+
+	var ks KeystoreData
+	if err = json.Unmarshal(keystoreJSON, &ks); err != nil {
+		return keys, false, fmt.Errorf("invalid keystore: %w", err)
+	}
+
+	if ks.Version != FormatVersion {
+		return keys, false, fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+	if ks.Crypto.Cipher != "aes-256-gcm" || ks.Crypto.KDF != "scrypt" {
+		return keys, false, fmt.Errorf("unsupported keystore cipher/kdf: %s/%s", ks.Crypto.Cipher, ks.Crypto.KDF)
+	}
+
+	params := ks.Crypto.KDFParams
+	if params.DKLen != 32 {
+		return keys, false, fmt.Errorf("unsupported keystore derived key length %d", params.DKLen)
+	}
+	if err = (ScryptParams{N: params.N, R: params.R, P: params.P}).Check(); err != nil {
+		return keys, false, fmt.Errorf("invalid keystore: %w", err)
+	}
+
+	meta, err := meta_address.DecodeString(ks.MetaAddress)
+	if err != nil {
+		return keys, false, fmt.Errorf("invalid keystore meta address: %w", err)
+	}
+	if meta.Version != ks.ProtocolVersion {
+		return keys, false, fmt.Errorf("keystore protocol version %s does not match the meta address version %s", ks.ProtocolVersion, meta.Version)
+	}
+
+	salt, err := hex.DecodeString(ks.Crypto.KDFParams.Salt)
+	if err != nil {
+		return keys, false, fmt.Errorf("invalid keystore salt: %w", err)
+	}
+	nonce, err := hex.DecodeString(ks.Crypto.CipherParams.Nonce)
+	if err != nil {
+		return keys, false, fmt.Errorf("invalid keystore nonce: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(ks.Crypto.Ciphertext)
+	if err != nil {
+		return keys, false, fmt.Errorf("invalid keystore ciphertext: %w", err)
+	}
+
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return keys, false, fmt.Errorf("error deriving key: %w", err)
+	}
+
+	aead, err := newAEAD(derivedKey)
+	if err != nil {
+		return keys, false, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return keys, false, fmt.Errorf("invalid keystore nonce length %d", len(nonce))
+	}
+
+	plaintextBytes, err := aead.Open(nil, nonce, ciphertext, ks.additionalData())
+	if err != nil {
+		return keys, false, ErrDecrypt
+	}
+
+	var plaintext privateKeysData
+	if err = json.Unmarshal(plaintextBytes, &plaintext); err != nil {
+		return keys, false, fmt.Errorf("invalid keystore content: %w", err)
+	}
+
+	keys, err = keysFromPrivateData(&plaintext, &meta, ks.WatchOnly)
+
+	return keys, ks.WatchOnly, err
+}
+
+// ReadMetaAddress returns the (public) meta address stored in the keystore, no passphrase needed
+func ReadMetaAddress(keystoreJSON []byte) (protocol.MetaAddress, error) {
+
+	var ks KeystoreData
+	if err := json.Unmarshal(keystoreJSON, &ks); err != nil {
+		return protocol.MetaAddress{}, fmt.Errorf("invalid keystore: %w", err)
+	}
+
+	return meta_address.DecodeString(ks.MetaAddress)
+}
+
+// Store writes the keystore to `path`, readable only by the owner
+func Store(path string, keystoreJSON []byte) error {
+	return os.WriteFile(path, keystoreJSON, 0600)
+}
+
+// Load reads and decrypts the keystore at `path`
+func Load(path string, passphrase string) (keys protocol.Keys, watchOnly bool, err error) {
+
+	keystoreJSON, err := os.ReadFile(path)
+	if err != nil {
+		return keys, false, err
+	}
+
+	return Decrypt(keystoreJSON, passphrase)
+}
+
+// LoadMetaAddress reads the (public) meta address from the keystore at `path`
+func LoadMetaAddress(path string) (protocol.MetaAddress, error) {
+
+	keystoreJSON, err := os.ReadFile(path)
+	if err != nil {
+		return protocol.MetaAddress{}, err
+	}
+
+	return ReadMetaAddress(keystoreJSON)
+}
+
+// Passphrase reads the passphrase from `passphraseFile` if given, otherwise from the ECPDKSAP_PASSPHRASE env. variable
+func Passphrase(passphraseFile string) (string, error) {
+
+	if passphraseFile != "" {
+		passphrase, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(passphrase), "\r\n"), nil
+	}
+
+	passphrase, ok := os.LookupEnv(PassphraseEnvVar)
+	if !ok {
+		return "", fmt.Errorf("keystore passphrase not provided (passphrase file or %s env. variable)", PassphraseEnvVar)
+	}
+
+	return passphrase, nil
+}
+
+func keysFromPrivateData(plaintext *privateKeysData, meta *protocol.MetaAddress, watchOnly bool) (keys protocol.Keys, err error) {
+
+	p, err := versions.Get(meta.Version)
+	if err != nil {
+		return keys, err
+	}
+
+	vBytes, err := hex.DecodeString(plaintext.PK_v)
+	if err != nil {
+		return keys, fmt.Errorf("invalid keystore content: %w", err)
+	}
+
+	if watchOnly {
+		if err = keys.PK_v.SetBytesCanonical(vBytes); err != nil {
+			return keys, fmt.Errorf("invalid private viewing key 'v': %w", err)
+		}
+
+		if V, _ := utils.BN254_CalcG1PubKey(keys.PK_v); V != meta.V {
+			return keys, fmt.Errorf("private viewing key does not match the meta address")
+		}
+
+		keys.Meta = *meta
+
+		return keys, nil
+	}
+
+	kBytes, err := hex.DecodeString(plaintext.PK_k)
+	if err != nil {
+		return keys, fmt.Errorf("invalid keystore content: %w", err)
+	}
+
+	if keys, err = p.KeysFromPrivate(kBytes, vBytes); err != nil {
+		return keys, err
+	}
+
+	if keys.Meta != *meta {
+		return keys, fmt.Errorf("private keys do not match the meta address")
+	}
+
+	return keys, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func newUUID() string {
+
+	var u [16]byte
+	rand.Read(u[:])
+
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // variant RFC 4122
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// binds the plaintext header fields to the ciphertext
+func (ks *KeystoreData) additionalData() []byte {
+	return []byte(fmt.Sprintf("%d|%s|%s|%t", ks.Version, ks.ProtocolVersion, ks.MetaAddress, ks.WatchOnly))
+}
+
+type KeystoreData struct {
+	Version         int        `json:"version"`
+	Id              string     `json:"id"`
+	ProtocolVersion string     `json:"protocolVersion"`
+	MetaAddress     string     `json:"metaAddress"`
+	WatchOnly       bool       `json:"watchOnly"`
+	Crypto          CryptoData `json:"crypto"`
+}
+
+type CryptoData struct {
+	Cipher       string           `json:"cipher"`
+	Ciphertext   string           `json:"ciphertext"`
+	CipherParams CipherParamsData `json:"cipherparams"`
+	KDF          string           `json:"kdf"`
+	KDFParams    KDFParamsData    `json:"kdfparams"`
+}
+
+type CipherParamsData struct {
+	Nonce string `json:"nonce"`
+}
+
+type KDFParamsData struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+type privateKeysData struct {
+	PK_k string `json:"k,omitempty"`
+	PK_v string `json:"v"`
+}
@@ -0,0 +1,285 @@
+package listener
+
+// Live scanning: the listener polls the chain for new blocks, scans their ECPDKSAP announcements and notifies
+// the matches. The hashes of the last `MaxReorgDepth` processed blocks are kept, so when a block is
+// reorganized away, the matches of the dropped blocks are notified as removed and the new blocks are scanned.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ecpdksap-go/chain"
+	"ecpdksap-go/events"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/utils"
+)
+
+const DefaultPollInterval = 12 * time.Second
+
+// Number of blocks that can be reorganized away (older blocks are considered final)
+const DefaultMaxReorgDepth = 64
+
+// ErrReorgTooDeep is returned when none of the kept blocks is on the chain anymore
+var ErrReorgTooDeep = errors.New("reorg deeper than the kept blocks")
+
+// Notification of a match, or of its removal when its block was reorganized away
+type Notification struct {
+	// note: `Match.Index` is the position in the polled batch, `BlockNumber` and `LogIndex` locate the announcement
+	Match recipient.Match
+
+	BlockHash string
+	Removed   bool
+}
+
+// Listener follows the chain from `next` block on, see: `Poll`, `Run`
+type Listener struct {
+	Client    chain.Client
+	Scanner   *recipient.Scanner
+	Announcer string
+
+	PollInterval  time.Duration
+	MaxBlockRange uint64
+	MaxReorgDepth uint64
+
+	// Called for every new match and every removed match (in chain order, removals newest first),
+	// an error stops the poll (the range being notified is rescanned, so notified again, by the next one)
+	Notify func(Notification) error
+
+	// note: the announcements of the reorganized blocks are counted again when rescanned
+	Stats recipient.Stats
+
+	next    uint64
+	blocks  []chain.Header // last processed blocks (ascending, consecutive)
+	matches []Notification // matches in `blocks`
+}
+
+func New(client chain.Client, s *recipient.Scanner, announcer string, fromBlock uint64, notify func(Notification) error) *Listener {
+	return &Listener{
+		Client:    client,
+		Scanner:   s,
+		Announcer: announcer,
+
+		PollInterval:  DefaultPollInterval,
+		MaxBlockRange: chain.DefaultMaxBlockRange,
+		MaxReorgDepth: DefaultMaxReorgDepth,
+
+		Notify: notify,
+
+		next: fromBlock,
+	}
+}
+
+// NextBlock returns the first block not processed yet
+func (l *Listener) NextBlock() uint64 {
+	return l.next
+}
+
+// Run polls every `PollInterval` until the context is done (returns its error) or polling fails
+func (l *Listener) Run(ctx context.Context) error {
+
+	ticker := time.NewTicker(l.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.Poll(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll rolls back the reorganized blocks (if any) and scans the new blocks up to the chain head,
+// when the chain changes during the poll, the remaining blocks are left to the next one
+func (l *Listener) Poll(ctx context.Context) error {
+
+	if err := l.checkReorg(ctx); err != nil {
+		return err
+	}
+
+	head, err := l.Client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	for l.next <= head {
+
+		done, err := l.processRange(ctx, chain.BatchEnd(l.next, head, l.MaxBlockRange), head)
+		if err != nil || !done {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processRange scans the blocks from `next` to `toBlock`, not done when the chain changed in the meantime
+func (l *Listener) processRange(ctx context.Context, toBlock uint64, head uint64) (done bool, err error) {
+
+	// Headers of the blocks that can still be reorganized away, before and after fetching the logs: a fork in
+	// between is not always visible in the logs (e.g. a block without logs replaced by one with an announcement)
+
+	fromHeader := l.next
+	if head >= l.MaxReorgDepth && head-l.MaxReorgDepth+1 > fromHeader {
+		fromHeader = head - l.MaxReorgDepth + 1
+	}
+
+	headers, err := l.headers(ctx, fromHeader, toBlock)
+	if err != nil || headers == nil {
+		return false, err
+	}
+
+	logs, err := l.Client.GetLogs(ctx, chain.AnnouncementsQuery(l.Announcer, l.next, toBlock))
+	if err != nil {
+		return false, err
+	}
+
+	headersAfter, err := l.headers(ctx, fromHeader, toBlock)
+	if err != nil || headersAfter == nil {
+		return false, err
+	}
+
+	blockHashes := map[uint64]string{}
+	for i := range headers {
+		if headers[i].Hash != headersAfter[i].Hash {
+			return false, nil
+		}
+		blockHashes[headers[i].Number] = headers[i].Hash
+	}
+
+	//note: the logs must be from the same blocks as the headers
+	for i := range logs {
+		blockNumber, err := utils.ParseQuantity(logs[i].BlockNumber)
+		if err != nil {
+			return false, fmt.Errorf("log %d: invalid block number: %w", i, err)
+		}
+		if hash, ok := blockHashes[blockNumber]; ok && hash != logs[i].BlockHash {
+			return false, nil
+		}
+		blockHashes[blockNumber] = logs[i].BlockHash
+	}
+
+	var notifications []Notification
+	err = l.Scanner.ScanStream(events.NewLogReader(logs, l.Scanner.ViewTagVersion), func(m recipient.Match) error {
+		notifications = append(notifications, Notification{Match: m, BlockHash: blockHashes[m.BlockNumber]})
+		return nil
+	}, &l.Stats)
+	if err != nil {
+		return false, err
+	}
+
+	for _, n := range notifications {
+		if err = l.Notify(n); err != nil {
+			return false, err
+		}
+	}
+
+	//note: without the headers of the range start, the kept blocks are not followed anymore (older ones are final)
+	if len(headers) == 0 || headers[0].Number != l.next {
+		l.blocks = nil
+	}
+
+	l.next = toBlock + 1
+	l.blocks = append(l.blocks, headers...)
+	l.matches = append(l.matches, notifications...)
+
+	// Only the last `MaxReorgDepth` blocks (and their matches) are kept
+
+	if uint64(len(l.blocks)) > l.MaxReorgDepth {
+		l.blocks = l.blocks[uint64(len(l.blocks))-l.MaxReorgDepth:]
+	}
+
+	matches := l.matches[:0]
+	for _, n := range l.matches {
+		if len(l.blocks) != 0 && n.Match.BlockNumber >= l.blocks[0].Number {
+			matches = append(matches, n)
+		}
+	}
+	l.matches = matches
+
+	return true, nil
+}
+
+// headers returns the headers of the blocks from `fromBlock` to `toBlock` (none when `fromBlock > toBlock`),
+// nil when they are not a chain following the last processed block (the chain changed in the meantime)
+func (l *Listener) headers(ctx context.Context, fromBlock uint64, toBlock uint64) ([]chain.Header, error) {
+
+	headers := []chain.Header{}
+
+	for number := fromBlock; number <= toBlock; number++ {
+
+		header, err := l.Client.HeaderByNumber(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+
+		//note: the parent must be the previous header (or the last processed block)
+		parent := l.lastBlock()
+		if len(headers) != 0 {
+			parent = &headers[len(headers)-1]
+		}
+		if header == nil || (parent != nil && parent.Number+1 == number && parent.Hash != header.ParentHash) {
+			return nil, nil
+		}
+
+		headers = append(headers, *header)
+	}
+
+	return headers, nil
+}
+
+// checkReorg finds the last kept block still on the chain, the matches of the later blocks are removed
+func (l *Listener) checkReorg(ctx context.Context) error {
+
+	i := len(l.blocks) - 1
+	for ; i >= 0; i-- {
+		header, err := l.Client.HeaderByNumber(ctx, l.blocks[i].Number)
+		if err != nil {
+			return err
+		}
+		if header != nil && header.Hash == l.blocks[i].Hash {
+			break
+		}
+	}
+
+	if i == len(l.blocks)-1 {
+		return nil
+	}
+	if i < 0 {
+		return fmt.Errorf("%w: block %d (%s) was reorganized away", ErrReorgTooDeep, l.blocks[0].Number, l.blocks[0].Hash)
+	}
+
+	ancestor := l.blocks[i].Number
+
+	for len(l.matches) != 0 && l.matches[len(l.matches)-1].Match.BlockNumber > ancestor {
+
+		n := l.matches[len(l.matches)-1]
+		n.Removed = true
+
+		if err := l.Notify(n); err != nil {
+			return err
+		}
+
+		l.matches = l.matches[:len(l.matches)-1]
+	}
+
+	l.blocks = l.blocks[:i+1]
+	l.next = ancestor + 1
+
+	return nil
+}
+
+func (l *Listener) lastBlock() *chain.Header {
+
+	if len(l.blocks) == 0 {
+		return nil
+	}
+
+	return &l.blocks[len(l.blocks)-1]
+}
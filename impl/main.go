@@ -1,19 +1,29 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+
 	"ecpdksap-go/benchmark"
 	"ecpdksap-go/gen_example"
+	"ecpdksap-go/key_derivation"
+	"ecpdksap-go/keygen"
+	"ecpdksap-go/keystore"
+	"ecpdksap-go/protocol"
 	"ecpdksap-go/recipient"
 	"ecpdksap-go/sender"
+	"ecpdksap-go/sweep"
 	"fmt"
 	"os"
 	"strconv"
 )
 
+const mnemonicPassphraseEnvVar = "ECPDKSAP_MNEMONIC_PASSPHRASE"
+
 func main() {
 
 	if len(os.Args) == 1 {
-		panic(`No subcommand passed - 'send' | 'receive-scan' | 'gen-example' | 'bench' subcommands allowed!`)
+		panic(`No subcommand passed - 'send' | 'receive-scan' | 'receive-scan-stream' | 'view-scan' | 'sweep' | 'gen-example' | 'keygen' | 'bench' subcommands allowed!`)
 	}
 
 	subcmd := os.Args[1]
@@ -24,23 +34,130 @@ func main() {
 		if len(os.Args) != 3 {
 			panic(`Subcommand 'send' receives all info. as one JSON input string!`)
 		}
-		sender.Send(os.Args[2])
+		res, err := sender.SendFromJSON(os.Args[2])
+		exitOnErr(err)
+
+		jsonBytes, _ := json.MarshalIndent(res, "", " ")
+		fmt.Println(string(jsonBytes))
 
 	case "receive-scan":
 		if len(os.Args) != 3 {
 			panic(`Subcommand 'receive-scan' receives all info. as one JSON input string!`)
 		}
-		recipient.Scan(os.Args[2])
+		res, stats, err := recipient.ScanFromJSON(os.Args[2])
+		exitOnErr(err)
+
+		jsonBytes, _ := json.MarshalIndent(res, "", " ")
+		fmt.Println(string(jsonBytes))
+
+		fmt.Fprintln(os.Stderr, stats)
+
+	case "receive-scan-stream":
+		if len(os.Args) != 3 {
+			panic(`Subcommand 'receive-scan-stream' receives all info. as one JSON input string (announcements are read from the 'Announcements' file or stdin)!`)
+		}
+		stats, err := recipient.ScanStreamFromJSON(os.Args[2], os.Stdin, os.Stdout)
+		exitOnErr(err)
+
+		fmt.Fprintln(os.Stderr, stats)
+
+	case "view-scan":
+		if len(os.Args) != 3 {
+			panic(`Subcommand 'view-scan' receives all info. as one JSON input string!`)
+		}
+		res, stats, err := recipient.ViewScanFromJSON(os.Args[2])
+		exitOnErr(err)
+
+		jsonBytes, _ := json.MarshalIndent(res, "", " ")
+		fmt.Println(string(jsonBytes))
+
+		fmt.Fprintln(os.Stderr, stats)
+
+	case "sweep":
+		if len(os.Args) != 3 {
+			panic(`Subcommand 'sweep' receives all info. as one JSON input string!`)
+		}
+		res, err := sweep.SweepFromJSON(os.Args[2])
+		exitOnErr(err)
+
+		jsonBytes, _ := json.MarshalIndent(res, "", " ")
+		fmt.Println(string(jsonBytes))
 
 	case "gen-example":
 		if len(os.Args) != 5 {
-			panic(`Subcommand 'gen-example' needs: <version: v0 | v2> <view-tag-version: none | v0-1byte | v0-2bytes | v1-1byte> <sample-size: uint>!`)
+			panic(`Subcommand 'gen-example' needs: <version: v0 | v2> <view-tag-version: none | v0-1byte | v0-2bytes | v1-1byte | v0-11nibbles> <sample-size: uint>!`)
 		}
 		gen_example.GenerateExample(os.Args[2], os.Args[3], os.Args[4])
 
+	case "keygen":
+		flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+		version := flags.String("version", "", "protocol version: v0 | v1 | v2")
+		out := flags.String("out", "", "file to write the private keys to in plaintext (only the meta address is printed)")
+		keystorePath := flags.String("keystore", "", "file to write the encrypted keystore to (only the meta address is printed)")
+		watchOnlyKeystorePath := flags.String("watch-only-keystore", "", "file to write the encrypted watch-only (v, K) keystore to")
+		passphraseFile := flags.String("passphrase-file", "", "file containing the keystore passphrase (default: "+keystore.PassphraseEnvVar+" env. variable)")
+		mnemonicFile := flags.String("mnemonic-file", "", "file containing a BIP-39 mnemonic to derive the keys from (BIP-39 passphrase: "+mnemonicPassphraseEnvVar+" env. variable)")
+		account := flags.Uint("account", 0, "account index used in the derivation path (with --mnemonic-file)")
+		flags.Parse(os.Args[2:])
+
+		if *version == "" {
+			panic(`Subcommand 'keygen' needs: --version <v0 | v1 | v2> [--mnemonic-file <file> [--account <uint>]] [--out <file>] [--keystore <file>] [--watch-only-keystore <file>] [--passphrase-file <file>]!`)
+		}
+
+		var keys protocol.Keys
+		var keysData keygen.KeysOutputData
+		var err error
+
+		if *mnemonicFile != "" {
+			mnemonic, err := os.ReadFile(*mnemonicFile)
+			exitOnErr(err)
+
+			seed, err := key_derivation.SeedFromMnemonic(string(mnemonic), os.Getenv(mnemonicPassphraseEnvVar))
+			exitOnErr(err)
+
+			if *account >= 1<<31 {
+				exitOnErr(fmt.Errorf("account index %d out of range", *account))
+			}
+
+			keys, keysData, err = keygen.DeriveKeys(*version, seed, uint32(*account))
+		} else {
+			keys, keysData, err = keygen.GenerateKeys(*version)
+		}
+		exitOnErr(err)
+
+		jsonBytes, _ := json.MarshalIndent(keysData, "", " ")
+
+		if *out == "" && *keystorePath == "" && *watchOnlyKeystorePath == "" {
+			fmt.Println(string(jsonBytes))
+			return
+		}
+
+		if *out != "" {
+			exitOnErr(os.WriteFile(*out, jsonBytes, 0600))
+		}
+
+		if *keystorePath != "" || *watchOnlyKeystorePath != "" {
+			passphrase, err := keystore.Passphrase(*passphraseFile)
+			exitOnErr(err)
+
+			if *keystorePath != "" {
+				ks, err := keystore.Encrypt(&keys, passphrase, keystore.StandardScryptParams)
+				exitOnErr(err)
+				exitOnErr(keystore.Store(*keystorePath, ks))
+			}
+
+			if *watchOnlyKeystorePath != "" {
+				ks, err := keystore.EncryptWatchOnly(&keys, passphrase, keystore.StandardScryptParams)
+				exitOnErr(err)
+				exitOnErr(keystore.Store(*watchOnlyKeystorePath, ks))
+			}
+		}
+
+		fmt.Println(keysData.MetaAddress)
+
 	case "bench":
 		if len(os.Args) < 3 {
-			panic(`Subcommand 'bench' takes one argument <only-bn254 | all-curves>!`)
+			panic(`Subcommand 'bench' takes one argument <only-bn254 | only-bn254-crk | scan-scaling | all-curves | all-results-from-paper>!`)
 		}
 
 		if len(os.Args) == 4 {
@@ -54,7 +171,15 @@ func main() {
 		}
 
 	default:
-		fmt.Printf("\nERR: Only: 'send' | 'receive-scan' | 'gen-example' | 'bench' subcommands allowed.\n\n")
+		fmt.Printf("\nERR: Only: 'send' | 'receive-scan' | 'receive-scan-stream' | 'view-scan' | 'sweep' | 'gen-example' | 'keygen' | 'bench' subcommands allowed.\n\n")
 		return
 	}
 }
+
+func exitOnErr(err error) {
+
+	if err != nil {
+		fmt.Printf("\nERR: %v\n\n", err)
+		os.Exit(1)
+	}
+}
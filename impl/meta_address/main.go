@@ -0,0 +1,173 @@
+package meta_address
+
+// Binary format of the stealth meta address (as registered in `ECPDKSAP_MetaAddressRegistry`):
+//
+//	| scheme id (2 bytes, big-endian: 3327) | protocol version (1 byte) | K | V |
+//
+//	K: BN254 G2 point, compressed (64 bytes) - v0, v1
+//	   SECP256k1 point, SEC1 compressed (33 bytes) - v2
+//	V: BN254 G1 point, compressed (32 bytes)
+//
+// Human-readable format: "st:eth:0x" followed by the hex encoded binary format.
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+const SchemeId = 3327
+
+const StringPrefix = "st:eth:0x"
+
+const headerLen = 3
+
+// Encode serializes the meta address into its binary format
+func Encode(meta *protocol.MetaAddress) ([]byte, error) {
+
+	p, err := versions.Get(meta.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	versionNumber, err := versionToByte(meta.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	res := binary.BigEndian.AppendUint16(nil, SchemeId)
+	res = append(res, versionNumber)
+
+	if p.SpendingKeyGroup() == protocol.SpendingKeyGroup_BN254_G2 {
+		K_asBytes := meta.K_G2.Bytes()
+		res = append(res, K_asBytes[:]...)
+	} else {
+		K_asBytes := utils.SECP256k1_CompressG1Point(&meta.K_SECP256k1)
+		res = append(res, K_asBytes[:]...)
+	}
+
+	V_asBytes := meta.V.Bytes()
+	res = append(res, V_asBytes[:]...)
+
+	return res, nil
+}
+
+// Decode parses (and validates) the binary format of the meta address
+func Decode(data []byte) (meta protocol.MetaAddress, err error) {
+
+	if len(data) < headerLen {
+		return meta, fmt.Errorf("meta address too short: %d bytes", len(data))
+	}
+
+	if schemeId := binary.BigEndian.Uint16(data[:2]); schemeId != SchemeId {
+		return meta, fmt.Errorf("unsupported scheme id %d, expected %d", schemeId, SchemeId)
+	}
+
+	p, err := versions.Get("v" + strconv.Itoa(int(data[2])))
+	if err != nil {
+		return meta, err
+	}
+	meta.Version = p.Version()
+
+	rest := data[headerLen:]
+
+	var KLen int
+	if p.SpendingKeyGroup() == protocol.SpendingKeyGroup_BN254_G2 {
+		KLen = BN254.SizeOfG2AffineCompressed
+	} else {
+		KLen = 33
+	}
+
+	if len(rest) != KLen+BN254.SizeOfG1AffineCompressed {
+		return meta, fmt.Errorf("invalid meta address length for %s: %d bytes", meta.Version, len(data))
+	}
+
+	if p.SpendingKeyGroup() == protocol.SpendingKeyGroup_BN254_G2 {
+		if meta.K_G2, err = utils.BN254_G2PointFromBytes(rest[:KLen]); err != nil {
+			return meta, fmt.Errorf("invalid public spending key 'K': %w", err)
+		}
+	} else {
+		if meta.K_SECP256k1, err = utils.SECP256k1_DecompressG1Point(rest[:KLen]); err != nil {
+			return meta, fmt.Errorf("invalid public spending key 'K': %w", err)
+		}
+	}
+
+	if meta.V, err = utils.BN254_G1PointFromBytes(rest[KLen:]); err != nil {
+		return meta, fmt.Errorf("invalid public viewing key 'V': %w", err)
+	}
+
+	return meta, nil
+}
+
+// Validate checks whether the binary format of the meta address is valid
+func Validate(data []byte) error {
+
+	_, err := Decode(data)
+
+	return err
+}
+
+// EncodeString serializes the meta address into its human-readable format ("st:eth:0x...")
+func EncodeString(meta *protocol.MetaAddress) (string, error) {
+
+	data, err := Encode(meta)
+	if err != nil {
+		return "", err
+	}
+
+	return StringPrefix + hex.EncodeToString(data), nil
+}
+
+// DecodeString parses (and validates) the human-readable format of the meta address
+func DecodeString(in string) (meta protocol.MetaAddress, err error) {
+
+	if !strings.HasPrefix(in, StringPrefix) {
+		return meta, fmt.Errorf("meta address must start with %q", StringPrefix)
+	}
+
+	data, err := hex.DecodeString(in[len(StringPrefix):])
+	if err != nil {
+		return meta, fmt.Errorf("meta address is not hex encoded: %w", err)
+	}
+
+	return Decode(data)
+}
+
+func versionToByte(version string) (byte, error) {
+
+	n, err := strconv.ParseUint(strings.TrimPrefix(version, "v"), 10, 8)
+	if err != nil || !strings.HasPrefix(version, "v") {
+		return 0, fmt.Errorf("protocol version %q can not be encoded", version)
+	}
+
+	return byte(n), nil
+}
+
+// ParseSpendingKey parses the textual form of the public spending key K into the meta address (of the set version):
+// BN254 G2 point (v0, v1, see: `utils.BN254_G2PointFromString`) or SECP256k1 point (v2, see: `utils.SECP256k1_G1PointFromXY`)
+func ParseSpendingKey(meta *protocol.MetaAddress, K string) (err error) {
+
+	p, err := versions.Get(meta.Version)
+	if err != nil {
+		return err
+	}
+
+	if p.SpendingKeyGroup() == protocol.SpendingKeyGroup_BN254_G2 {
+		meta.K_G2, err = utils.BN254_G2PointFromString(K)
+	} else {
+		meta.K_SECP256k1, err = utils.SECP256k1_G1PointFromXY(K)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid public spending key 'K': %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"errors"
+	"math/big"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
+	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+)
+
+var ErrUnsupported = errors.New("operation not supported by this protocol version")
+
+// SpendingKeyGroup denotes the group in which the recipient's public spending key K lives
+type SpendingKeyGroup int
+
+const (
+	SpendingKeyGroup_BN254_G2 SpendingKeyGroup = iota
+	SpendingKeyGroup_SECP256k1
+)
+
+// Protocol is implemented by every ECPDKSAP version (see: `./versions`)
+type Protocol interface {
+
+	// Version returns the protocol version identifier (v0, v1, v2)
+	Version() string
+
+	// SpendingKeyGroup returns the group of the public spending key K
+	SpendingKeyGroup() SpendingKeyGroup
+
+	// GenerateMetaAddress generates random recipient's keys together with the corresponding meta address
+	GenerateMetaAddress() (Keys, error)
+
+	// KeysFromPrivate reconstructs recipient's keys from the raw private spending (k) and viewing (v) keys
+	KeysFromPrivate(k []byte, v []byte) (Keys, error)
+
+	// DeriveStealth computes the stealth info. - from sender's perspective
+	DeriveStealth(r *BN254_fr.Element, meta *MetaAddress) (Stealth, error)
+
+	// CheckAnnouncement computes the stealth info. for the announced R - from recipient's perspective
+	// note: vR (= v·R) is passed in when it was already computed for the view tag check, otherwise it is nil
+	CheckAnnouncement(keys *Keys, R *BN254.G1Affine, vR *BN254.G1Affine) (Stealth, error)
+
+	// DeriveStealthPrivateKey computes the private key corresponding to the stealth public key:
+	//	v0: k·v (BN254 Fr), P == e(R, G2)^(k·v)
+	//	v1: hash(v·R)·k (BN254 Fr), P == e(G1, G2)^(hash(v·R)·k)
+	//	v2: k·b (SECP256k1 Fr), P == (k·b)·G
+	DeriveStealthPrivateKey(keys *Keys, stealth *Stealth) (*big.Int, error)
+
+	// NewChecker precomputes the recipient's values (v's GLV decomposition, the pairing's fixed G2 argument lines)
+	// used for checking many announcements
+	NewChecker(keys *Keys) (Checker, error)
+}
+
+// Checker checks announcements for one recipient, reusing the precomputed values (safe for concurrent use)
+type Checker interface {
+
+	// SharedPoint computes v·R
+	SharedPoint(R *BN254.G1Affine) BN254.G1Affine
+
+	// CheckAnnouncement - same as `Protocol.CheckAnnouncement`
+	CheckAnnouncement(R *BN254.G1Affine, vR *BN254.G1Affine) (Stealth, error)
+}
+
+// MetaAddress contains the recipient's public spending (K) and viewing (V) keys
+type MetaAddress struct {
+	Version string
+
+	K_G2        BN254.G2Affine     // v0, v1
+	K_SECP256k1 SECP256K1.G1Affine // v2
+
+	V BN254.G1Affine
+}
+
+// Keys contains the recipient's private spending (k) and viewing (v) keys
+type Keys struct {
+	PK_k_BN254     BN254_fr.Element     // v0, v1
+	PK_k_SECP256k1 SECP256K1_fr.Element // v2
+
+	PK_v BN254_fr.Element
+
+	Meta MetaAddress
+}
+
+// Stealth contains the values shared between the sender and the recipient
+type Stealth struct {
+	// Sender's ephemeral public key
+	R BN254.G1Affine
+
+	// r·V (sender) == v·R (recipient), used for the view tag calculation
+	SharedPoint BN254.G1Affine
+
+	// v0, v1: stealth public key; v2: shared secret S
+	P_GT BN254.GT
+
+	// v2: stealth public key b·K and the corresponding Ethereum address
+	P_SECP256k1 SECP256K1.G1Affine
+	Address     string
+}
+
+// PubKeyBytes serializes the stealth public key: marshalled GT element (BN254 G2 spending keys) or
+// uncompressed SECP256k1 point (SECP256k1 spending keys)
+func (s *Stealth) PubKeyBytes(group SpendingKeyGroup) []byte {
+
+	if group == SpendingKeyGroup_SECP256k1 {
+		P_asBytes := s.P_SECP256k1.RawBytes()
+		return P_asBytes[:]
+	}
+
+	return s.P_GT.Marshal()
+}
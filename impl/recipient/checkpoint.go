@@ -0,0 +1,192 @@
+package recipient
+
+// Incremental scanning: the position (block number, log index) of the last processed announcement
+// is stored per recipient after every batch, so the next scan only checks newer announcements.
+//
+// note: matches of a batch are emitted before its checkpoint is stored, so after a crash the
+// matches of the last (unfinished) batch are emitted again (at-least-once delivery)
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/validation"
+)
+
+// Checkpoint is the position of the last processed announcement
+type Checkpoint struct {
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// Before reports whether the checkpoint is positioned before the announcement
+func (cp *Checkpoint) Before(a *Announcement) bool {
+	return cp.BlockNumber < a.BlockNumber || (cp.BlockNumber == a.BlockNumber && cp.LogIndex < a.LogIndex)
+}
+
+// CheckpointStore persists the checkpoints by the recipient's id (see: `Scanner.RecipientId`)
+type CheckpointStore interface {
+	// Load returns `ok == false` if there is no checkpoint for the recipient
+	Load(recipientId string) (cp Checkpoint, ok bool, err error)
+
+	// Save replaces the recipient's checkpoint atomically
+	Save(recipientId string, cp Checkpoint) error
+}
+
+// FileCheckpointStore keeps the checkpoints of all recipients in one JSON file
+type FileCheckpointStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{Path: path}
+}
+
+func (store *FileCheckpointStore) Load(recipientId string) (cp Checkpoint, ok bool, err error) {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	checkpoints, err := store.read()
+	if err != nil {
+		return cp, false, err
+	}
+
+	cp, ok = checkpoints[recipientId]
+
+	return cp, ok, nil
+}
+
+// Save writes the whole file to a temporary file first and renames it over the old one
+func (store *FileCheckpointStore) Save(recipientId string, cp Checkpoint) error {
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	checkpoints, err := store.read()
+	if err != nil {
+		return err
+	}
+
+	checkpoints[recipientId] = cp
+
+	jsonBytes, _ := json.MarshalIndent(checkpoints, "", " ")
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.Path), filepath.Base(store.Path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error writing checkpoints: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(jsonBytes); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing checkpoints: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), store.Path); err != nil {
+		return fmt.Errorf("error writing checkpoints: %w", err)
+	}
+
+	return nil
+}
+
+func (store *FileCheckpointStore) read() (map[string]Checkpoint, error) {
+
+	checkpoints := map[string]Checkpoint{}
+
+	jsonBytes, err := os.ReadFile(store.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoints: %w", err)
+	}
+
+	if err = json.Unmarshal(jsonBytes, &checkpoints); err != nil {
+		return nil, fmt.Errorf("invalid checkpoints file %s: %w", store.Path, err)
+	}
+
+	return checkpoints, nil
+}
+
+// RecipientId identifies the recipient in the checkpoint store: its encoded meta address
+func (s *Scanner) RecipientId() (string, error) {
+	return meta_address.EncodeString(&s.Keys.Meta)
+}
+
+// ScanIncremental works as `ScanStream`, but skips the announcements up to the recipient's stored checkpoint
+// and stores the new checkpoint after every batch; returns the last checkpoint
+// note: the announcements must be ordered by their position (block number, log index)
+func (s *Scanner) ScanIncremental(in AnnouncementReader, store CheckpointStore, emit func(Match) error, stats *Stats) (cp Checkpoint, err error) {
+
+	recipientId, err := s.RecipientId()
+	if err != nil {
+		return cp, err
+	}
+
+	cp, hasCheckpoint, err := store.Load(recipientId)
+	if err != nil {
+		return cp, err
+	}
+
+	reader := &afterCheckpointReader{in: in, checkpoint: cp, hasCheckpoint: hasCheckpoint}
+
+	err = s.scanStream(reader, emit, func(last *Announcement) error {
+
+		next := Checkpoint{BlockNumber: last.BlockNumber, LogIndex: last.LogIndex}
+		if err := store.Save(recipientId, next); err != nil {
+			return err
+		}
+
+		cp = next
+		return nil
+	}, stats)
+
+	return cp, err
+}
+
+// afterCheckpointReader skips the announcements up to the checkpoint and rejects unordered input
+type afterCheckpointReader struct {
+	in AnnouncementReader
+
+	checkpoint    Checkpoint
+	hasCheckpoint bool
+
+	prev    Checkpoint
+	hasPrev bool
+}
+
+func (r *afterCheckpointReader) Next() (a Announcement, err error) {
+
+	for {
+		if a, err = r.in.Next(); err != nil && !validation.IsInvalidPoint(err) {
+			return a, err
+		}
+
+		if r.hasCheckpoint && !r.checkpoint.Before(&a) {
+			continue
+		}
+
+		if r.hasPrev && !r.prev.Before(&a) {
+			return a, fmt.Errorf("announcement at block %d, log %d is not ordered after block %d, log %d", a.BlockNumber, a.LogIndex, r.prev.BlockNumber, r.prev.LogIndex)
+		}
+
+		r.prev = Checkpoint{BlockNumber: a.BlockNumber, LogIndex: a.LogIndex}
+		r.hasPrev = true
+
+		return a, err
+	}
+}
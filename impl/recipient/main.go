@@ -5,244 +5,475 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
-	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
-	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
-	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
 
-	ecpdksap_v1 "ecpdksap-go/versions/v1"
-	ecpdksap_v2 "ecpdksap-go/versions/v2"
-
-	"ecpdksap-go/utils"
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/keystore"
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/validation"
+	"ecpdksap-go/versions"
+	"ecpdksap-go/view_tags"
 )
 
-func Scan(jsonInputString string) (rP []string, rAddr []string, privKeys []string) {
+// Scanner checks announcements (sender's public keys and view tags) against the recipient's keys
+type Scanner struct {
+	Protocol       protocol.Protocol
+	Keys           protocol.Keys
+	ViewTagVersion string
+
+	// Number of workers used by `Scan` (<= 0: one per CPU core, 1: sequential scan)
+	Parallelism int
 
-	// ------------------------ Unpacking json
+	checker       protocol.Checker
+	viewTagScheme view_tags.ViewTagScheme
 
-	var recipientInputData RecipientInputData
-	json.Unmarshal([]byte(jsonInputString), &recipientInputData)
+	// No private spending key (see: `NewWatchOnlyScanner`)
+	watchOnly bool
+}
+
+// Match describes an announcement that belongs to the recipient
+type Match struct {
+	// Index of the announcement in the scanned input
+	Index int
+
+	// Sender's public key
+	R BN254.G1Affine
+
+	// v0, v1: marshalled GT stealth public key; v2: uncompressed SECP256k1 stealth public key
+	StealthPubKey []byte
+
+	// v2: Ethereum address of the stealth public key
+	Address string
+
+	// Stealth private key, nil for a watch-only scanner (see: `NewWatchOnlyScanner`)
+	SpendingKey *big.Int
+
+	// Position of the announcement on the chain (streaming scan only, if known)
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// Stats contains the timing info. of a scan
+type Stats struct {
+	NAnnouncements int
+
+	// Number of announcements that passed the view tag check
+	NFullRuns int
 
-	Rs_string := recipientInputData.Rs
+	// Number of announcements skipped because of an invalid R (see: `validation.BN254_G1Point`)
+	NInvalid int
 
-	var Rs []BN254.G1Affine
+	// Wall-clock duration
+	Duration time.Duration
 
-	nFullRuns := 0
-	var duration time.Duration
+	// Phase 0: view tag calculation (summed over all workers)
+	ViewTagDuration time.Duration
 
-	var viewTagFcn func(*BN254.G1Affine, uint) string
-	nBytesInViewTag := uint(0)
-	if recipientInputData.ViewTagVersion == "none" {
-		//note: default values
-	} else if recipientInputData.ViewTagVersion == "v0-1byte" {
-		viewTagFcn = utils.BN254_G1PointToViewTag
-		nBytesInViewTag = 1
+	// Phase 1: remaining (pairing-based) calculation (summed over all workers)
+	RemainingDuration time.Duration
+}
+
+// add accumulates the counters and phase durations of `other` (but not its wall-clock duration)
+func (stats *Stats) add(other *Stats) {
+	stats.NAnnouncements += other.NAnnouncements
+	stats.NFullRuns += other.NFullRuns
+	stats.NInvalid += other.NInvalid
+	stats.ViewTagDuration += other.ViewTagDuration
+	stats.RemainingDuration += other.RemainingDuration
+}
+
+// Number of announcements a worker takes at once
+const scanBatchSize = 256
+
+func NewScanner(keys protocol.Keys, viewTagVersion string) (*Scanner, error) {
+
+	p, err := versions.Get(keys.Meta.Version)
+	if err != nil {
+		return nil, err
+	}
 
-	} else if recipientInputData.ViewTagVersion == "v0-2bytes" {
-		viewTagFcn = utils.BN254_G1PointToViewTag
-		nBytesInViewTag = 2
+	s := &Scanner{Protocol: p, Keys: keys, ViewTagVersion: viewTagVersion}
 
-	} else if recipientInputData.ViewTagVersion == "v1-1byte" {
-		viewTagFcn = utils.BN254_G1PointXCoordToViewTag
-		nBytesInViewTag = 1
+	//note: precomputed once, reused for all announcements
+	if s.checker, err = p.NewChecker(&s.Keys); err != nil {
+		return nil, err
 	}
 
-	var viewTagCalcAggregateDuration time.Duration
-	var remainingCalcAggregateDuration time.Duration
+	if s.viewTagScheme, err = view_tags.Get(viewTagVersion); err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < len(Rs_string); i++ {
+	return s, nil
+}
 
-		RsiX, RsiY := utils.UnpackXY(Rs_string[i])
+// Scan returns all announcements (Rs[i], viewTags[i]) that belong to the recipient, ordered by index
+// note: `stats` is optional (can be nil)
+func (s *Scanner) Scan(Rs []BN254.G1Affine, viewTags []string, stats *Stats) (matches []Match, err error) {
 
-		var Rsi BN254.G1Affine
-		Rsi.X.SetString(RsiX)
-		Rsi.Y.SetString(RsiY)
+	if s.viewTagScheme.Length() != 0 && len(viewTags) != len(Rs) {
+		return nil, fmt.Errorf("got %d view tags for %d announcements", len(viewTags), len(Rs))
+	}
 
-		Rs = append(Rs, Rsi)
+	if stats == nil {
+		stats = new(Stats)
 	}
+	stats.NAnnouncements += len(Rs)
 
-	if recipientInputData.Version == "v0" {
+	startTime := time.Now()
 
-		var k, v BN254_fr.Element
-		kBytes, _ := hex.DecodeString(recipientInputData.PK_k)
-		k.Unmarshal(kBytes)
-		vBytes, _ := hex.DecodeString(recipientInputData.PK_v)
-		v.Unmarshal(vBytes)
+	nBatches := (len(Rs) + scanBatchSize - 1) / scanBatchSize
 
-		K, _ := utils.BN254_CalcG2PubKey(k)
+	nWorkers := s.Parallelism
+	if nWorkers <= 0 {
+		nWorkers = runtime.NumCPU()
+	}
+	nWorkers = min(nWorkers, nBatches)
 
-		vBigInt := new(big.Int)
-		v.BigInt(vBigInt)
+	if nWorkers <= 1 {
+		matches, err = s.scanRange(Rs, viewTags, 0, len(Rs), stats)
+		stats.Duration += time.Since(startTime)
 
-		var P BN254.GT
+		return matches, err
+	}
 
-		startTime := time.Now()
+	// Workers take batches in order, results are collected per batch to keep the output order deterministic
 
-		for i, Rsi := range Rs {
+	batchMatches := make([][]Match, nBatches)
+	batchErrs := make([]error, nBatches)
+	workerStats := make([]Stats, nWorkers)
 
-			vTagCalcStart := time.Now()
+	batches := make(chan int, nBatches)
+	for b := 0; b < nBatches; b++ {
+		batches <- b
+	}
+	close(batches)
 
-			if viewTagFcn != nil {
-				vR := utils.BN254_MulG1PointandElement(&Rsi, &v)
+	var wg sync.WaitGroup
+	var failed atomic.Bool
 
-				calculatedViewTag := viewTagFcn(&vR, nBytesInViewTag)
+	for w := 0; w < nWorkers; w++ {
+		wg.Add(1)
 
-				viewTagCalcAggregateDuration += time.Since(vTagCalcStart)
+		go func(workerStats *Stats) {
+			defer wg.Done()
 
-				if calculatedViewTag != recipientInputData.ViewTags[i][:2*nBytesInViewTag] {
-					continue
+			for b := range batches {
+				if failed.Load() {
+					return
 				}
-			}
 
-			nFullRuns += 1
+				from := b * scanBatchSize
+				to := min(from+scanBatchSize, len(Rs))
 
-			pairingResult, _ := BN254.Pair([]BN254.G1Affine{Rsi}, []BN254.G2Affine{K})
+				batchMatches[b], batchErrs[b] = s.scanRange(Rs, viewTags, from, to, workerStats)
+				if batchErrs[b] != nil {
+					failed.Store(true)
+				}
+			}
+		}(&workerStats[w])
+	}
 
-			rCalcStart := time.Now()
-			P.CyclotomicExp(pairingResult, vBigInt)
+	wg.Wait()
 
-			// P, _ := ecpdksap_v0.RecipientComputesStealthPubKey(&K, &Rsi, &v);
+	for w := range workerStats {
+		stats.add(&workerStats[w])
+	}
 
-			remainingCalcAggregateDuration += time.Since(rCalcStart)
+	for b := range batchMatches {
+		matches = append(matches, batchMatches[b]...)
 
-			rP = append(rP, hex.EncodeToString(P.Marshal()))
+		if batchErrs[b] != nil {
+			err = batchErrs[b]
+			break
 		}
+	}
 
-		duration = time.Since(startTime)
+	stats.Duration += time.Since(startTime)
 
-	} else if recipientInputData.Version == "v1" {
+	return matches, err
+}
 
-		var k, v BN254_fr.Element
-		kBytes, _ := hex.DecodeString(recipientInputData.PK_k)
-		k.Unmarshal(kBytes)
-		vBytes, _ := hex.DecodeString(recipientInputData.PK_v)
-		v.Unmarshal(vBytes)
+func (s *Scanner) scanRange(Rs []BN254.G1Affine, viewTags []string, from int, to int, stats *Stats) (matches []Match, err error) {
 
-		K, _ := utils.BN254_CalcG2PubKey(k)
+	for i := from; i < to; i++ {
 
-		startTime := time.Now()
+		match, ok, err := s.check(i, &Rs[i], viewTags, stats)
+		if err != nil {
+			return matches, err
+		}
 
-		for i, Rsi := range Rs {
+		if ok {
+			matches = append(matches, match)
+		}
+	}
 
-			vTagCalcStart := time.Now()
+	return matches, nil
+}
 
-			if viewTagFcn != nil {
-				vR := utils.BN254_MulG1PointandElement(&Rsi, &v)
+func (s *Scanner) check(i int, R *BN254.G1Affine, viewTags []string, stats *Stats) (match Match, ok bool, err error) {
 
-				calculatedViewTag := viewTagFcn(&vR, nBytesInViewTag)
+	//note: anyone can announce, R is never passed to the scalar multiplication / pairing unchecked
+	if validation.BN254_G1Point(R) != nil {
+		stats.NInvalid += 1
+		return match, false, nil
+	}
 
-				viewTagCalcAggregateDuration += time.Since(vTagCalcStart)
+	var vR *BN254.G1Affine
 
-				if calculatedViewTag != recipientInputData.ViewTags[i][:2*nBytesInViewTag] {
-					continue
-				}
-			}
+	if s.viewTagScheme.Length() != 0 {
+		vTagCalcStart := time.Now()
 
-			nFullRuns += 1
+		tmp := s.checker.SharedPoint(R)
+		vR = &tmp
 
-			rCalcStart := time.Now()
+		calculatedViewTag := s.viewTagScheme.Compute(vR)
 
-			P := ecpdksap_v1.ViewerComputesStealthPubKey(&K, &Rsi, &v)
+		stats.ViewTagDuration += time.Since(vTagCalcStart)
 
-			remainingCalcAggregateDuration += time.Since(rCalcStart)
+		if !s.viewTagScheme.Compare(calculatedViewTag, viewTags[i]) {
+			return match, false, nil
+		}
+	}
+
+	stats.NFullRuns += 1
+
+	rCalcStart := time.Now()
 
-			rP = append(rP, hex.EncodeToString(P.Marshal()))
+	stealth, err := s.checker.CheckAnnouncement(R, vR)
+	if err != nil {
+		return match, false, fmt.Errorf("announcement %d: %w", i, err)
+	}
+
+	match = Match{
+		Index:         i,
+		R:             *R,
+		StealthPubKey: stealth.PubKeyBytes(s.Protocol.SpendingKeyGroup()),
+		Address:       stealth.Address,
+	}
+
+	if !s.watchOnly {
+		if match.SpendingKey, err = s.Protocol.DeriveStealthPrivateKey(&s.Keys, &stealth); err != nil {
+			return match, false, fmt.Errorf("announcement %d: error deriving the stealth private key: %w", i, err)
 		}
+	}
 
-		duration = time.Since(startTime)
+	stats.RemainingDuration += time.Since(rCalcStart)
 
-	} else if recipientInputData.Version == "v2" {
+	return match, true, nil
+}
 
-		var k SECP256K1_fr.Element
-		kBytes, _ := hex.DecodeString(recipientInputData.PK_k)
-		k.Unmarshal(kBytes)
-		var k_asBigInt big.Int
-		k.BigInt(&k_asBigInt)
+// String formats the stats as the (legacy) CLI timing report
+func (stats Stats) String() string {
 
-		var v BN254_fr.Element
-		vBytes, _ := hex.DecodeString(recipientInputData.PK_v)
-		v.Unmarshal(vBytes)
-		var v_asBigInt big.Int
-		v.BigInt(&v_asBigInt)
+	if stats.NAnnouncements == 0 {
+		return fmt.Sprint("----> nFullRuns: ", stats.NFullRuns, " (no announcements)")
+	}
 
-		var K SECP256K1.G1Affine
-		K.ScalarMultiplicationBase(&k_asBigInt)
+	var nInvalid string
+	if stats.NInvalid != 0 {
+		nInvalid = fmt.Sprint("\n----> nInvalid: ", stats.NInvalid)
+	}
 
-		var V BN254.G1Affine
-		V.ScalarMultiplicationBase(&v_asBigInt)
+	sampleSize := time.Duration(stats.NAnnouncements)
 
-		_, _, _, G2_BN254 := BN254.Generators()
+	if stats.NFullRuns != 0 {
+		return fmt.Sprintln("----> nFullRuns: ", stats.NFullRuns, "avgDuration:", stats.Duration/sampleSize) +
+			fmt.Sprintln("Phase 0 avg. duration: ", stats.ViewTagDuration/sampleSize) +
+			fmt.Sprint("Phase 1 avg. duration: ", stats.RemainingDuration/time.Duration(stats.NFullRuns)) + nInvalid
+	}
 
-		var vR BN254.G1Affine
-		var b SECP256K1_fr.Element
-		var P SECP256K1.G1Affine
-		var kb SECP256K1_fr.Element
+	return fmt.Sprintln("----> nFullRuns: ", stats.NFullRuns) +
+		fmt.Sprint("Phase 0 avg. duration: ", stats.ViewTagDuration/sampleSize) + nInvalid
+}
 
-		startTime := time.Now()
+// ParseRecipientInput unpacks the JSON input (see: `RecipientInputData`) into a scanner and the announcements
+func ParseRecipientInput(jsonInputString string) (s *Scanner, Rs []BN254.G1Affine, viewTags []string, err error) {
 
-		for i, Rsi := range Rs {
+	var recipientInputData RecipientInputData
+	if err = json.Unmarshal([]byte(jsonInputString), &recipientInputData); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid JSON input: %w", err)
+	}
 
-			vTagCalcStart := time.Now()
+	s, err = newScannerFromInput(&recipientInputData)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-			vR = utils.BN254_MulG1PointandElement(&Rsi, &v)
+	if Rs, err = parseRs(&recipientInputData); err != nil {
+		return nil, nil, nil, err
+	}
 
-			if viewTagFcn != nil {
+	return s, Rs, recipientInputData.ViewTags, nil
+}
 
-				calculatedViewTag := viewTagFcn(&vR, nBytesInViewTag)
+func parseRs(recipientInputData *RecipientInputData) (Rs []BN254.G1Affine, err error) {
 
-				viewTagCalcAggregateDuration += time.Since(vTagCalcStart)
+	for i, Rsi_string := range recipientInputData.Rs {
 
-				if calculatedViewTag != recipientInputData.ViewTags[i][:2*nBytesInViewTag] {
-					continue
-				}
-			}
+		Rsi, err := announcement.ParseR(Rsi_string)
+		if err = keepInvalidPoint(&Rsi, err); err != nil {
+			return nil, fmt.Errorf("invalid sender's public key Rs[%d]: %w", i, err)
+		}
 
-			nFullRuns += 1
+		Rs = append(Rs, Rsi)
+	}
 
-			rCalcStart := time.Now()
+	return Rs, nil
+}
 
-			S, _ := BN254.Pair([]BN254.G1Affine{vR}, []BN254.G2Affine{G2_BN254})
-			b = ecpdksap_v2.Compute_b_asElement(&S)
+// keepInvalidPoint replaces an invalid R (see: `validation.InvalidPointError`) by the infinity, so the announcement
+// keeps its index and `Scan` skips and counts it, any other error is returned
+func keepInvalidPoint(R *BN254.G1Affine, err error) error {
 
-			kb.Mul(&k, &b)
+	if validation.IsInvalidPoint(err) {
+		*R = BN254.G1Affine{}
+		return nil
+	}
 
-			P = utils.SECP256k1_MulG1PointandElement(&K, &b)
+	return err
+}
 
-			remainingCalcAggregateDuration += time.Since(rCalcStart)
+func newScannerFromInput(recipientInputData *RecipientInputData) (*Scanner, error) {
 
-			rP = append(rP, hex.EncodeToString(S.Marshal()))
+	keys, err := loadKeys(recipientInputData)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewScanner(keys, recipientInputData.ViewTagVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.Parallelism = recipientInputData.Parallelism
 
-			rAddr = append(rAddr, ecpdksap_v2.ComputeEthAddress(&P))
-			privKeys = append(privKeys, "0x"+kb.Text(16))
+	return s, nil
+}
 
+func loadKeys(recipientInputData *RecipientInputData) (keys protocol.Keys, err error) {
+
+	if recipientInputData.Keystore != "" {
+
+		passphrase, err := keystore.Passphrase(recipientInputData.PassphraseFile)
+		if err != nil {
+			return keys, err
 		}
 
-		duration = time.Since(startTime)
+		keys, watchOnly, err := keystore.Load(recipientInputData.Keystore, passphrase)
+		if err != nil {
+			return keys, err
+		}
+
+		if watchOnly {
+			return keys, fmt.Errorf("watch-only keystore does not contain the private spending key 'k'")
+		}
+
+		if recipientInputData.Version != "" && recipientInputData.Version != keys.Meta.Version {
+			return keys, fmt.Errorf("version %s does not match the keystore version %s", recipientInputData.Version, keys.Meta.Version)
+		}
+
+		return keys, nil
+	}
+
+	p, err := versions.Get(recipientInputData.Version)
+	if err != nil {
+		return keys, err
+	}
+
+	kBytes, err := hex.DecodeString(recipientInputData.PK_k)
+	if err != nil {
+		return keys, fmt.Errorf("private key 'k' is not a hex string: %w", err)
+	}
+	vBytes, err := hex.DecodeString(recipientInputData.PK_v)
+	if err != nil {
+		return keys, fmt.Errorf("private key 'v' is not a hex string: %w", err)
+	}
+
+	return p.KeysFromPrivate(kBytes, vBytes)
+}
+
+// ScanFromJSON is the JSON (CLI) entrypoint wrapping `Scanner.Scan`
+func ScanFromJSON(jsonInputString string) (output RecipientOutputData, stats Stats, err error) {
+
+	s, Rs, viewTags, err := ParseRecipientInput(jsonInputString)
+	if err != nil {
+		return output, stats, err
 	}
 
-	fmt.Println("ECPDKSAP ::: version:", recipientInputData.Version, "ViewTagVersion:", recipientInputData.ViewTagVersion, "; time:", duration)
+	matches, err := s.Scan(Rs, viewTags, &stats)
+	if err != nil {
+		return output, stats, err
+	}
 
-	sampleSize := time.Duration(len(Rs_string))
+	output.Matches = []MatchOutputData{}
 
-	if nFullRuns != 0 {
-		fmt.Println("----> nFullRuns: ", nFullRuns, "avgDuration:", duration/sampleSize)
-		fmt.Println("Phase 0 avg. duration: ", viewTagCalcAggregateDuration/sampleSize)
-		fmt.Println("Phase 1 avg. duration: ", remainingCalcAggregateDuration/time.Duration(nFullRuns))
-	} else {
-		fmt.Println("----> nFullRuns: ", nFullRuns)
-		fmt.Println("Phase 0 avg. duration: ", viewTagCalcAggregateDuration/sampleSize)
+	for i := range matches {
+		output.Matches = append(output.Matches, toMatchOutputData(&matches[i]))
 	}
 
-	return
+	return output, stats, nil
+}
+
+func toMatchOutputData(m *Match) MatchOutputData {
+
+	mOut := MatchOutputData{
+		Index:         m.Index,
+		R:             hex.EncodeToString(announcement.EncodeR(&m.R)),
+		StealthPubKey: hex.EncodeToString(m.StealthPubKey),
+		Address:       m.Address,
+		BlockNumber:   m.BlockNumber,
+		LogIndex:      m.LogIndex,
+	}
+
+	if m.SpendingKey != nil {
+		mOut.SpendingKey = "0x" + m.SpendingKey.Text(16)
+	}
+
+	return mOut
 }
 
 type RecipientInputData struct {
-	PK_k           string   `json:"k"`
-	PK_v           string   `json:"v"`
+	PK_k string `json:"k"`
+	PK_v string `json:"v"`
+
+	// Alternative to k and v: encrypted keystore path (passphrase from the file or ECPDKSAP_PASSPHRASE env. variable)
+	Keystore       string `json:",omitempty"`
+	PassphraseFile string `json:",omitempty"`
+
+	// View scan only (with v, instead of k): the recipient's meta address or public spending key K (see: `ViewScanFromJSON`)
+	MetaAddress string `json:",omitempty"`
+	K           string `json:",omitempty"`
+
 	Rs             []string `json:"Rs"`
 	Version        string
 	ViewTags       []string
 	ViewTagVersion string
+
+	// Number of scanning workers (default: one per CPU core)
+	Parallelism int `json:",omitempty"`
+
+	// Streaming scan only (instead of Rs and ViewTags): announcements file ("-": stdin) and its format ("ndjson" | "binary")
+	Announcements       string `json:",omitempty"`
+	AnnouncementsFormat string `json:",omitempty"`
+
+	// Streaming scan only: checkpoints file, if set only the announcements after the recipient's checkpoint are checked
+	Checkpoints string `json:",omitempty"`
+}
+
+type RecipientOutputData struct {
+	Matches []MatchOutputData
+}
+
+type MatchOutputData struct {
+	Index         int
+	R             string
+	StealthPubKey string
+	Address       string `json:",omitempty"`
+	SpendingKey   string `json:",omitempty"`
+	BlockNumber   uint64 `json:",omitempty"`
+	LogIndex      uint   `json:",omitempty"`
 }
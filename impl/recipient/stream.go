@@ -0,0 +1,293 @@
+package recipient
+
+// Streaming scan: announcements are read from an `io.Reader` in batches and the matches are emitted
+// as soon as their batch is checked, so the memory use does not depend on the number of announcements.
+//
+// Supported input formats:
+//
+//	ndjson: one JSON object per line: {"R": "<hex compressed R>", "ViewTag": "<hex view tag>"},
+//	        optionally with the announcement's chain position: "BlockNumber": uint, "LogIndex": uint
+//	binary: concatenated records: | R (32 bytes, compressed) | view tag (`ViewTagScheme.Length()` bytes) |
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/view_tags"
+)
+
+const (
+	StreamFormat_NDJSON = "ndjson"
+	StreamFormat_Binary = "binary"
+)
+
+// Number of announcements (per worker) read before the batch is scanned
+const streamBatchSize = 4 * scanBatchSize
+
+// Announcement is a sender's public key with its view tag and (optional) position on the chain
+type Announcement struct {
+	R       BN254.G1Affine
+	ViewTag string
+
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// AnnouncementReader returns the next announcement, `io.EOF` when there are none left
+// note: for an invalid R (see: `validation.InvalidPointError`) the announcement is returned with the error,
+// the scan skips it and carries on
+type AnnouncementReader interface {
+	Next() (Announcement, error)
+}
+
+// AnnouncementData is one line of the NDJSON stream
+type AnnouncementData struct {
+	R       string
+	ViewTag string `json:",omitempty"`
+
+	BlockNumber uint64 `json:",omitempty"`
+	LogIndex    uint   `json:",omitempty"`
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewNDJSONReader reads announcements stored as one JSON object (see: `AnnouncementData`) per line
+func NewNDJSONReader(in io.Reader) AnnouncementReader {
+	return &ndjsonReader{scanner: bufio.NewScanner(in)}
+}
+
+func (r *ndjsonReader) Next() (a Announcement, err error) {
+
+	for r.scanner.Scan() {
+		r.line++
+
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var data AnnouncementData
+		if err = json.Unmarshal(r.scanner.Bytes(), &data); err != nil {
+			return a, fmt.Errorf("line %d: invalid JSON: %w", r.line, err)
+		}
+
+		a.ViewTag = data.ViewTag
+		a.BlockNumber = data.BlockNumber
+		a.LogIndex = data.LogIndex
+
+		//note: the announcement (position) is returned with the invalid point error too
+		if a.R, err = announcement.ParseR(data.R); err != nil {
+			return a, fmt.Errorf("line %d: %w", r.line, err)
+		}
+
+		return a, nil
+	}
+
+	if err = r.scanner.Err(); err != nil {
+		return a, err
+	}
+
+	return a, io.EOF
+}
+
+type binaryReader struct {
+	in             *bufio.Reader
+	viewTagVersion string
+	record         []byte
+	index          int
+}
+
+// NewBinaryReader reads announcements stored as fixed size records (see: `EncodeBinaryRecord`)
+func NewBinaryReader(in io.Reader, viewTagVersion string) (AnnouncementReader, error) {
+
+	viewTagScheme, err := view_tags.Get(viewTagVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryReader{
+		in:             bufio.NewReader(in),
+		viewTagVersion: viewTagVersion,
+		record:         make([]byte, BN254.SizeOfG1AffineCompressed+viewTagScheme.Length()),
+	}, nil
+}
+
+func (r *binaryReader) Next() (a Announcement, err error) {
+
+	if _, err = io.ReadFull(r.in, r.record); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return a, fmt.Errorf("record %d: truncated", r.index)
+		}
+		return a, err
+	}
+
+	r.index++
+
+	a.R, a.ViewTag, err = announcement.Decode(r.record[:BN254.SizeOfG1AffineCompressed], r.record[BN254.SizeOfG1AffineCompressed:], r.viewTagVersion)
+	if err != nil {
+		return a, fmt.Errorf("record %d: %w", r.index-1, err)
+	}
+
+	return a, nil
+}
+
+// EncodeBinaryRecord serializes the announcement into the binary stream record
+func EncodeBinaryRecord(R *BN254.G1Affine, viewTag string, viewTagVersion string) ([]byte, error) {
+
+	ephemeralPubKey, metadata, err := announcement.Encode(R, viewTag, viewTagVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ephemeralPubKey, metadata...), nil
+}
+
+// ScanStream checks all announcements from `in` and calls `emit` for each match (in input order);
+// `Match.Index` is the position of the announcement in the stream
+// note: `stats` is optional (can be nil)
+func (s *Scanner) ScanStream(in AnnouncementReader, emit func(Match) error, stats *Stats) error {
+	return s.scanStream(in, emit, nil, stats)
+}
+
+// scanStream calls `afterBatch` (if set) with the last announcement of every batch, once all its matches were emitted
+func (s *Scanner) scanStream(in AnnouncementReader, emit func(Match) error, afterBatch func(last *Announcement) error, stats *Stats) error {
+
+	if stats == nil {
+		stats = new(Stats)
+	}
+
+	nWorkers := s.Parallelism
+	if nWorkers <= 0 {
+		nWorkers = runtime.NumCPU()
+	}
+	batchSize := nWorkers * streamBatchSize
+
+	//note: reused for all batches
+	batch := make([]Announcement, 0, batchSize)
+	Rs := make([]BN254.G1Affine, 0, batchSize)
+	viewTags := make([]string, 0, batchSize)
+
+	offset := 0
+
+	for done := false; !done; {
+
+		batch, Rs, viewTags = batch[:0], Rs[:0], viewTags[:0]
+
+		for len(batch) < batchSize {
+
+			a, err := in.Next()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err = keepInvalidPoint(&a.R, err); err != nil {
+				return fmt.Errorf("error reading announcement %d: %w", offset+len(batch), err)
+			}
+
+			batch = append(batch, a)
+			Rs = append(Rs, a.R)
+			viewTags = append(viewTags, a.ViewTag)
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+
+		matches, err := s.Scan(Rs, viewTags, stats)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range matches {
+			m.BlockNumber = batch[m.Index].BlockNumber
+			m.LogIndex = batch[m.Index].LogIndex
+			m.Index += offset
+
+			if err := emit(m); err != nil {
+				return err
+			}
+		}
+
+		if afterBatch != nil {
+			if err := afterBatch(&batch[len(batch)-1]); err != nil {
+				return err
+			}
+		}
+
+		offset += len(batch)
+	}
+
+	return nil
+}
+
+// ScanStreamFromJSON is the JSON (CLI) entrypoint wrapping `Scanner.ScanStream`:
+// the announcements are read from `RecipientInputData.Announcements` and the matches
+// are written to `out` as NDJSON (see: `MatchOutputData`)
+func ScanStreamFromJSON(jsonInputString string, stdin io.Reader, out io.Writer) (stats Stats, err error) {
+
+	var recipientInputData RecipientInputData
+	if err = json.Unmarshal([]byte(jsonInputString), &recipientInputData); err != nil {
+		return stats, fmt.Errorf("invalid JSON input: %w", err)
+	}
+
+	s, err := newScannerFromInput(&recipientInputData)
+	if err != nil {
+		return stats, err
+	}
+
+	var input io.Reader
+	switch recipientInputData.Announcements {
+	case "":
+		return stats, fmt.Errorf("announcements file not set")
+	case "-":
+		input = stdin
+	default:
+		f, err := os.Open(recipientInputData.Announcements)
+		if err != nil {
+			return stats, err
+		}
+		defer f.Close()
+		input = f
+	}
+
+	var reader AnnouncementReader
+	switch recipientInputData.AnnouncementsFormat {
+	case "", StreamFormat_NDJSON:
+		reader = NewNDJSONReader(input)
+	case StreamFormat_Binary:
+		if reader, err = NewBinaryReader(input, recipientInputData.ViewTagVersion); err != nil {
+			return stats, err
+		}
+	default:
+		return stats, fmt.Errorf("unknown announcements format %q, supported: %s, %s", recipientInputData.AnnouncementsFormat, StreamFormat_NDJSON, StreamFormat_Binary)
+	}
+
+	w := bufio.NewWriter(out)
+	encoder := json.NewEncoder(w)
+
+	emit := func(m Match) error {
+		if err := encoder.Encode(toMatchOutputData(&m)); err != nil {
+			return err
+		}
+		//note: flushed per match, so that the matches are visible immediately
+		return w.Flush()
+	}
+
+	if recipientInputData.Checkpoints != "" {
+		_, err = s.ScanIncremental(reader, NewFileCheckpointStore(recipientInputData.Checkpoints), emit, &stats)
+	} else {
+		err = s.ScanStream(reader, emit, &stats)
+	}
+
+	return stats, err
+}
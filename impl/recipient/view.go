@@ -0,0 +1,151 @@
+package recipient
+
+// Watch-only (view) scanning: the viewing party (e.g. an auditor) holds only the private viewing key v and the
+// recipient's public keys (V, K), which is enough to find the recipient's stealth public keys / addresses, but
+// not to spend: all stealth private keys (v0: k·v, v1: hash(v·R)·k, v2: k·b) need the private spending key k.
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+
+	"ecpdksap-go/keystore"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/utils"
+)
+
+// NewWatchOnlyScanner creates a scanner using only the viewing keys (`PK_v` and `Meta`, see: `ViewingKeys`),
+// the private spending key is dropped (if set) and the matches never contain the stealth private key
+func NewWatchOnlyScanner(keys protocol.Keys, viewTagVersion string) (*Scanner, error) {
+
+	keys.PK_k_BN254 = BN254_fr.Element{}
+	keys.PK_k_SECP256k1 = SECP256K1_fr.Element{}
+
+	s, err := NewScanner(keys, viewTagVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.watchOnly = true
+
+	return s, nil
+}
+
+// ViewingKeys assembles the viewing keys from the private viewing key v and the recipient's meta address,
+// the meta address' V is set from v if missing, otherwise it must match v
+func ViewingKeys(vBytes []byte, meta protocol.MetaAddress) (keys protocol.Keys, err error) {
+
+	if err = keys.PK_v.SetBytesCanonical(vBytes); err != nil {
+		return keys, fmt.Errorf("invalid private viewing key 'v': %w", err)
+	}
+
+	V, _ := utils.BN254_CalcG1PubKey(keys.PK_v)
+
+	if meta.V.IsInfinity() {
+		meta.V = V
+	} else if !meta.V.Equal(&V) {
+		return keys, fmt.Errorf("private viewing key 'v' does not match the meta address")
+	}
+
+	keys.Meta = meta
+
+	return keys, nil
+}
+
+// ViewScanFromJSON is the JSON (CLI) entrypoint of the watch-only scan, takes (see: `RecipientInputData`):
+//
+//	v with the recipient's MetaAddress or K and Version, or a (full or watch-only) Keystore
+//
+// and the announcements as `ScanFromJSON`; the private spending key k is rejected
+func ViewScanFromJSON(jsonInputString string) (output RecipientOutputData, stats Stats, err error) {
+
+	var recipientInputData RecipientInputData
+	if err = json.Unmarshal([]byte(jsonInputString), &recipientInputData); err != nil {
+		return output, stats, fmt.Errorf("invalid JSON input: %w", err)
+	}
+
+	keys, err := loadViewingKeys(&recipientInputData)
+	if err != nil {
+		return output, stats, err
+	}
+
+	s, err := NewWatchOnlyScanner(keys, recipientInputData.ViewTagVersion)
+	if err != nil {
+		return output, stats, err
+	}
+	s.Parallelism = recipientInputData.Parallelism
+
+	Rs, err := parseRs(&recipientInputData)
+	if err != nil {
+		return output, stats, err
+	}
+
+	matches, err := s.Scan(Rs, recipientInputData.ViewTags, &stats)
+	if err != nil {
+		return output, stats, err
+	}
+
+	output.Matches = []MatchOutputData{}
+
+	for i := range matches {
+		output.Matches = append(output.Matches, toMatchOutputData(&matches[i]))
+	}
+
+	return output, stats, nil
+}
+
+func loadViewingKeys(recipientInputData *RecipientInputData) (keys protocol.Keys, err error) {
+
+	if recipientInputData.PK_k != "" {
+		return keys, fmt.Errorf("view scan does not take the private spending key 'k'")
+	}
+
+	if recipientInputData.Keystore != "" {
+
+		passphrase, err := keystore.Passphrase(recipientInputData.PassphraseFile)
+		if err != nil {
+			return keys, err
+		}
+
+		//note: full keystores are accepted too, k is dropped by the watch-only scanner
+		if keys, _, err = keystore.Load(recipientInputData.Keystore, passphrase); err != nil {
+			return keys, err
+		}
+
+		if recipientInputData.Version != "" && recipientInputData.Version != keys.Meta.Version {
+			return keys, fmt.Errorf("version %s does not match the keystore version %s", recipientInputData.Version, keys.Meta.Version)
+		}
+
+		return keys, nil
+	}
+
+	var meta protocol.MetaAddress
+
+	if recipientInputData.MetaAddress != "" {
+
+		if meta, err = meta_address.DecodeString(recipientInputData.MetaAddress); err != nil {
+			return keys, fmt.Errorf("invalid meta address: %w", err)
+		}
+
+		if recipientInputData.Version != "" && recipientInputData.Version != meta.Version {
+			return keys, fmt.Errorf("version %s does not match the meta address version %s", recipientInputData.Version, meta.Version)
+		}
+	} else {
+
+		meta.Version = recipientInputData.Version
+
+		if err = meta_address.ParseSpendingKey(&meta, recipientInputData.K); err != nil {
+			return keys, err
+		}
+	}
+
+	vBytes, err := hex.DecodeString(recipientInputData.PK_v)
+	if err != nil {
+		return keys, fmt.Errorf("private key 'v' is not a hex string: %w", err)
+	}
+
+	return ViewingKeys(vBytes, meta)
+}
@@ -3,115 +3,226 @@ package sender
 import (
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 
 	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
 	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
-	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
-	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
 
-	ecpdksap_v0 "ecpdksap-go/versions/v0"
-	ecpdksap_v1 "ecpdksap-go/versions/v1"
-	ecpdksap_v2 "ecpdksap-go/versions/v2"
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/keystore"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/validation"
+	"ecpdksap-go/versions"
+	"ecpdksap-go/view_tags"
 
 	"ecpdksap-go/utils"
 )
 
-func Send(jsonInputString string) (rr string, rR string, rVTag string, rP string) {
+// SendRequest contains everything the sender needs to compute the recipient's stealth info.
+type SendRequest struct {
+	// Sender's ephemeral private key
+	PK_r BN254_fr.Element
 
-	// ------------------------ Unpacking json
+	// Recipient's meta address (incl. the protocol version)
+	Meta protocol.MetaAddress
+
+	ViewTagVersion string
+
+	// Optional (JSON entrypoint): announcer function to build the calldata of, see: `SendResult.AnnouncerCalldata`
+	AnnouncerFunction string
+}
+
+// SendResult contains the values the sender announces and the stealth destination
+type SendResult struct {
+	// Sender's ephemeral public key
+	R BN254.G1Affine
+
+	ViewTag string
+
+	// v0, v1: marshalled GT stealth public key; v2: uncompressed SECP256k1 stealth public key
+	StealthPubKey []byte
+
+	// v2: Ethereum address of the stealth public key
+	StealthAddress string
+}
+
+// Send computes the recipient's stealth info. for the given request
+func Send(req SendRequest) (res SendResult, err error) {
+
+	p, err := versions.Get(req.Meta.Version)
+	if err != nil {
+		return res, err
+	}
+
+	viewTagScheme, err := view_tags.Get(req.ViewTagVersion)
+	if err != nil {
+		return res, err
+	}
+
+	if err = validation.MetaAddress(&req.Meta, p.SpendingKeyGroup()); err != nil {
+		return res, err
+	}
+
+	stealth, err := p.DeriveStealth(&req.PK_r, &req.Meta)
+	if err != nil {
+		return res, fmt.Errorf("error computing stealth info.: %w", err)
+	}
+
+	res.R = stealth.R
+
+	res.StealthPubKey = stealth.PubKeyBytes(p.SpendingKeyGroup())
+	res.StealthAddress = stealth.Address
+
+	//note: r·V, the recipient computes the view tag from v·R (see: `recipient.Scanner.Scan`)
+	res.ViewTag = viewTagScheme.Compute(&stealth.SharedPoint)
+
+	return res, nil
+}
+
+// ParseSendRequest unpacks the JSON input (see: `SenderInputData`) into a `SendRequest`
+func ParseSendRequest(jsonInputString string) (req SendRequest, err error) {
 
 	var senderInputData SenderInputData
-	json.Unmarshal([]byte(jsonInputString), &senderInputData)
+	if err = json.Unmarshal([]byte(jsonInputString), &senderInputData); err != nil {
+		return req, fmt.Errorf("invalid JSON input: %w", err)
+	}
 
-	if senderInputData.Version == "v0" {
+	rBytes, err := hex.DecodeString(senderInputData.PK_r)
+	if err != nil {
+		return req, fmt.Errorf("private key 'r' is not a hex string: %w", err)
+	}
+	if err = req.PK_r.SetBytesCanonical(rBytes); err != nil {
+		return req, fmt.Errorf("invalid private key 'r': %w", err)
+	}
 
-		var r BN254_fr.Element
-		rBytes, _ := hex.DecodeString(senderInputData.PK_r)
-		r.Unmarshal(rBytes)
+	req.ViewTagVersion = senderInputData.ViewTagVersion
+	req.AnnouncerFunction = senderInputData.AnnouncerFunction
 
-		var K BN254.G2Affine
-		Kx, Ky := utils.UnpackXY(senderInputData.K)
-		K.X.SetString(Kx, Ky)
+	if senderInputData.MetaAddress != "" || senderInputData.Keystore != "" {
 
-		var V BN254.G1Affine
-		Vx, Vy := utils.UnpackXY(senderInputData.V)
-		V.X.SetString(Vx)
-		V.Y.SetString(Vy)
+		if senderInputData.Keystore != "" {
+			req.Meta, err = keystore.LoadMetaAddress(senderInputData.Keystore)
+		} else {
+			req.Meta, err = meta_address.DecodeString(senderInputData.MetaAddress)
+		}
+		if err != nil {
+			return req, fmt.Errorf("invalid meta address: %w", err)
+		}
 
-		// ------------------------ Stealh Pub. Key computation
+		if senderInputData.Version != "" && senderInputData.Version != req.Meta.Version {
+			return req, fmt.Errorf("version %s does not match the meta address version %s", senderInputData.Version, req.Meta.Version)
+		}
 
-		R, _ := utils.BN254_CalcG1PubKey(r)
-		P, _ := ecpdksap_v0.SenderComputesStealthPubKey(&r, &V, &K)
+		return req, nil
+	}
 
-		// ------------------------ Return val. calc.
+	p, err := versions.Get(senderInputData.Version)
+	if err != nil {
+		return req, err
+	}
 
-		rr = hex.EncodeToString(r.Marshal())
-		rR = hex.EncodeToString(R.Marshal())
-		rVTag = utils.ComputeViewTag(senderInputData.ViewTagVersion, &V)
-		rP = hex.EncodeToString(P.Marshal())
+	req.Meta.Version = p.Version()
 
-	} else if senderInputData.Version == "v1" {
+	if err = meta_address.ParseSpendingKey(&req.Meta, senderInputData.K); err != nil {
+		return req, err
+	}
 
-		var r BN254_fr.Element
-		rBytes, _ := hex.DecodeString(senderInputData.PK_r)
-		r.Unmarshal(rBytes)
+	if req.Meta.V, err = utils.BN254_G1PointFromXY(senderInputData.V); err != nil {
+		return req, fmt.Errorf("invalid public viewing key 'V': %w", err)
+	}
 
-		var K BN254.G2Affine
-		Kx, Ky := utils.UnpackXY(senderInputData.K)
-		K.X.SetString(Kx, Ky)
+	return req, nil
+}
 
-		var V BN254.G1Affine
-		Vx, Vy := utils.UnpackXY(senderInputData.V)
-		V.X.SetString(Vx)
-		V.Y.SetString(Vy)
+// AnnouncerCalldata returns the calldata of the announcer function ("sendEthViaProxy" | "ethSentWithoutProxy" |
+// "announce", see: `announcement.SendEthViaProxyCalldata` etc.) announcing the result
+// note: v0, v1 have no stealth address, so only `ethSentWithoutProxy` and `announce` (zero address) apply
+func (res *SendResult) AnnouncerCalldata(function string, viewTagVersion string) ([]byte, error) {
 
-		// ------------------------ Stealh Pub. Key computation
+	ephemeralPubKey, metadata, err := announcement.Encode(&res.R, res.ViewTag, viewTagVersion)
+	if err != nil {
+		return nil, err
+	}
 
-		R, _ := utils.BN254_CalcG1PubKey(r)
-		P, _ := ecpdksap_v1.SenderComputesStealthPubKey(&r, &V, &K)
+	switch function {
 
-		// ------------------------ Return val. calc.
+	case "sendEthViaProxy":
+		if res.StealthAddress == "" {
+			return nil, fmt.Errorf("sendEthViaProxy needs the stealth address (v2 only)")
+		}
+		return announcement.SendEthViaProxyCalldata(res.StealthAddress, ephemeralPubKey, metadata)
 
-		rr = hex.EncodeToString(r.Marshal())
-		rR = hex.EncodeToString(R.Marshal())
-		rVTag = utils.ComputeViewTag(senderInputData.ViewTagVersion, &V)
-		rP = hex.EncodeToString(P.Marshal())
+	case "ethSentWithoutProxy":
+		return announcement.EthSentWithoutProxyCalldata(ephemeralPubKey, metadata), nil
 
-	} else if senderInputData.Version == "v2" {
+	case "announce":
+		return announcement.AnnounceCalldata(res.StealthAddress, ephemeralPubKey, metadata)
+	}
 
-		var K SECP256K1.G1Affine
-		Kx, Ky := utils.UnpackXY(senderInputData.K)
-		K.X.SetString(Kx)
-		K.Y.SetString(Ky)
+	return nil, fmt.Errorf("unknown announcer function %q, allowed: sendEthViaProxy | ethSentWithoutProxy | announce", function)
+}
 
-		var V BN254.G1Affine
-		Vx, Vy := utils.UnpackXY(senderInputData.V)
-		V.X.SetString(Vx)
-		V.Y.SetString(Vy)
+// SendFromJSON is the JSON (CLI) entrypoint wrapping `Send`
+func SendFromJSON(jsonInputString string) (SenderOutputData, error) {
 
-		var r BN254_fr.Element
-		rBytes, _ := hex.DecodeString(senderInputData.PK_r)
-		r.Unmarshal(rBytes)
+	req, err := ParseSendRequest(jsonInputString)
+	if err != nil {
+		return SenderOutputData{}, err
+	}
 
-		GT := ecpdksap_v2.SenderComputesSharedSecret(&r, &V, &K)
+	res, err := Send(req)
+	if err != nil {
+		return SenderOutputData{}, err
+	}
 
-		b := ecpdksap_v2.Compute_b(&GT)
-		var b_asElement SECP256K1_fr.Element
-		b_asElement.SetBigInt(&b)
+	ephemeralPubKey, metadata, err := announcement.Encode(&res.R, res.ViewTag, req.ViewTagVersion)
+	if err != nil {
+		return SenderOutputData{}, err
+	}
 
-		ecpdksap_v2.SenderComputesEthAddress(&b_asElement, &K)
+	output := SenderOutputData{
+		R:              hex.EncodeToString(ephemeralPubKey),
+		ViewTag:        res.ViewTag,
+		Metadata:       hex.EncodeToString(metadata),
+		StealthPubKey:  hex.EncodeToString(res.StealthPubKey),
+		StealthAddress: res.StealthAddress,
+	}
 
-		tmp := utils.BN254_MulG1PointandElement(&V, &r)
-		rVTag = utils.ComputeViewTag(senderInputData.ViewTagVersion, &tmp)
+	if req.AnnouncerFunction != "" {
+		calldata, err := res.AnnouncerCalldata(req.AnnouncerFunction, req.ViewTagVersion)
+		if err != nil {
+			return SenderOutputData{}, err
+		}
+		output.Calldata = "0x" + hex.EncodeToString(calldata)
 	}
 
-	return rr, rR, rVTag, rP
+	return output, nil
 }
 
 type SenderInputData struct {
-	PK_r           string `json:"r"`
+	PK_r string `json:"r"`
+
+	// Either the encoded meta address ("st:eth:0x..."), the recipient's keystore path or K, V and Version
+	MetaAddress string `json:",omitempty"`
+	Keystore    string `json:",omitempty"`
+
 	K              string `json:"K"`
 	V              string `json:"V"`
 	Version        string
 	ViewTagVersion string
+
+	// Optional announcer function to build the calldata of: sendEthViaProxy | ethSentWithoutProxy | announce
+	AnnouncerFunction string `json:",omitempty"`
+}
+
+type SenderOutputData struct {
+	R              string
+	ViewTag        string
+	Metadata       string
+	StealthPubKey  string
+	StealthAddress string `json:",omitempty"`
+
+	// Calldata of the `AnnouncerFunction` call (0x prefixed hex)
+	Calldata string `json:",omitempty"`
 }
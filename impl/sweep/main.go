@@ -0,0 +1,509 @@
+package sweep
+
+// Spending from the discovered v2 stealth addresses: EIP-1559 (type 2) transactions moving the whole balance
+// (minus the fee) to a destination, signed with the stealth private key k·b (secp256k1 ECDSA over keccak256).
+//
+//	signing hash: keccak256(0x02 || rlp([chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gas, to, value, data, accessList]))
+//	raw tx:       0x02 || rlp([chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gas, to, value, data, accessList, yParity, r, s])
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
+	SECP256K1_ecdsa "github.com/consensys/gnark-crypto/ecc/secp256k1/ecdsa"
+	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+
+	"ecpdksap-go/chain"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/utils"
+	ecpdksap_v2 "ecpdksap-go/versions/v2"
+)
+
+const DynamicFeeTxType = 0x02
+
+// Gas of a plain ETH transfer
+const TransferGas = 21_000
+
+// ErrInsufficientBalance is returned when the balance does not cover the transaction fee
+var ErrInsufficientBalance = errors.New("balance does not cover the fee")
+
+// Signatures with s above half the order are rejected by Ethereum (EIP-2)
+var secp256k1HalfOrder = new(big.Int).Rsh(SECP256K1_fr.Modulus(), 1)
+
+// Transaction is the EIP-1559 transaction (with an empty access list)
+type Transaction struct {
+	ChainId              *big.Int
+	Nonce                uint64
+	MaxPriorityFeePerGas *big.Int
+	MaxFeePerGas         *big.Int
+	Gas                  uint64
+	To                   string
+	Value                *big.Int
+	Data                 []byte
+}
+
+// Signature of the transaction, `YParity` is the recovery id
+type Signature struct {
+	YParity uint
+	R       *big.Int
+	S       *big.Int
+}
+
+type SignedTransaction struct {
+	From string
+	Tx   Transaction
+
+	Signature Signature
+	RawTx     []byte
+
+	// keccak256 of `RawTx`
+	Hash string
+}
+
+// Params of the sweep transactions
+type Params struct {
+	ChainId              *big.Int
+	Destination          string
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+
+	// Default: `TransferGas`
+	Gas uint64
+}
+
+// SigningHash is the hash signed by the sender
+func (tx *Transaction) SigningHash() ([]byte, error) {
+
+	fields, err := tx.rlpFields()
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.Keccak256(append([]byte{DynamicFeeTxType}, rlpList(fields...)...)), nil
+}
+
+// Sign signs the transaction with the private key, see: `SignHash`
+func (tx *Transaction) Sign(privKey *big.Int) (signed SignedTransaction, err error) {
+
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return signed, err
+	}
+
+	if signed.Signature, err = SignHash(hash, privKey); err != nil {
+		return signed, err
+	}
+	if signed.From, err = Address(privKey); err != nil {
+		return signed, err
+	}
+
+	signed.Tx = *tx
+	if signed.RawTx, err = tx.RawTransaction(signed.Signature); err != nil {
+		return signed, err
+	}
+	signed.Hash = "0x" + hex.EncodeToString(utils.Keccak256(signed.RawTx))
+
+	return signed, nil
+}
+
+// RawTransaction encodes the transaction with the (already computed) signature, as sent to `eth_sendRawTransaction`
+func (tx *Transaction) RawTransaction(sig Signature) ([]byte, error) {
+
+	if sig.R == nil || sig.S == nil {
+		return nil, fmt.Errorf("transaction: signature r and s must be set")
+	}
+
+	fields, err := tx.rlpFields()
+	if err != nil {
+		return nil, err
+	}
+
+	fields = append(fields, rlpUint(uint64(sig.YParity)), rlpBigUint(sig.R), rlpBigUint(sig.S))
+
+	return append([]byte{DynamicFeeTxType}, rlpList(fields...)...), nil
+}
+
+func (tx *Transaction) rlpFields() ([][]byte, error) {
+
+	if tx.ChainId == nil || tx.MaxPriorityFeePerGas == nil || tx.MaxFeePerGas == nil || tx.Value == nil {
+		return nil, fmt.Errorf("transaction: chain id, fees and value must be set")
+	}
+	if tx.ChainId.Sign() < 0 || tx.MaxPriorityFeePerGas.Sign() < 0 || tx.MaxFeePerGas.Sign() < 0 || tx.Value.Sign() < 0 {
+		return nil, fmt.Errorf("transaction: chain id, fees and value must not be negative")
+	}
+
+	to, err := utils.AddressWord(tx.To)
+	if err != nil {
+		return nil, fmt.Errorf("transaction: invalid recipient: %w", err)
+	}
+
+	return [][]byte{
+		rlpBigUint(tx.ChainId),
+		rlpUint(tx.Nonce),
+		rlpBigUint(tx.MaxPriorityFeePerGas),
+		rlpBigUint(tx.MaxFeePerGas),
+		rlpUint(tx.Gas),
+		rlpBytes(to[12:]),
+		rlpBigUint(tx.Value),
+		rlpBytes(tx.Data),
+		rlpList(),
+	}, nil
+}
+
+// SignHash signs the 32-byte hash: the recovery id is the parity of the nonce point's Y and s is at most
+// half the order (low-s, see: EIP-2)
+func SignHash(hash []byte, privKey *big.Int) (sig Signature, err error) {
+
+	if len(hash) != 32 {
+		return sig, fmt.Errorf("hash must be 32 bytes long, got %d", len(hash))
+	}
+
+	key, err := toECDSAPrivateKey(privKey)
+	if err != nil {
+		return sig, err
+	}
+
+	for {
+		v, r, s, err := key.SignForRecover(hash, nil)
+		if err != nil {
+			return sig, fmt.Errorf("error signing: %w", err)
+		}
+
+		//note: the nonce point's X exceeding the order (v >= 2) cannot be expressed by the recovery id,
+		// with probability ~2^-128
+		if v >= 2 {
+			continue
+		}
+
+		if s.Cmp(secp256k1HalfOrder) > 0 {
+			s.Sub(SECP256K1_fr.Modulus(), s)
+			v ^= 1
+		}
+
+		return Signature{YParity: v, R: r, S: s}, nil
+	}
+}
+
+// RecoverAddress returns the address of the hash's signer
+func RecoverAddress(hash []byte, sig Signature) (string, error) {
+
+	if sig.YParity >= 2 || sig.S.Cmp(secp256k1HalfOrder) > 0 {
+		return "", fmt.Errorf("invalid signature: recovery id %d, s %s", sig.YParity, sig.S)
+	}
+
+	var pub SECP256K1_ecdsa.PublicKey
+	if err := pub.RecoverFrom(hash, sig.YParity, sig.R, sig.S); err != nil {
+		return "", fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return ecpdksap_v2.ComputeEthAddress(&pub.A), nil
+}
+
+// Address returns the Ethereum address of the private key
+func Address(privKey *big.Int) (string, error) {
+
+	key, err := toECDSAPrivateKey(privKey)
+	if err != nil {
+		return "", err
+	}
+
+	return ecpdksap_v2.ComputeEthAddress(&key.PublicKey.A), nil
+}
+
+func toECDSAPrivateKey(privKey *big.Int) (key SECP256K1_ecdsa.PrivateKey, err error) {
+
+	if privKey == nil || privKey.Sign() <= 0 || privKey.Cmp(SECP256K1_fr.Modulus()) >= 0 {
+		return key, fmt.Errorf("private key must be in [1, secp256k1 order)")
+	}
+
+	var pub SECP256K1.G1Affine
+	pub.ScalarMultiplicationBase(privKey)
+
+	//note: the key is the public key (X || Y) followed by the scalar
+	pubBytes := pub.RawBytes()
+	_, err = key.SetBytes(append(pubBytes[:], privKey.FillBytes(make([]byte, SECP256K1_fr.Bytes))...))
+
+	return key, err
+}
+
+// SweepTransaction moves the whole balance minus the maximal fee (gas · maxFeePerGas) to the destination,
+// `ErrInsufficientBalance` if nothing is left
+// note: the part of the maximal fee not charged (maxFeePerGas above the base fee plus the tip) stays at the stealth address
+func SweepTransaction(params *Params, nonce uint64, balance *big.Int) (*Transaction, error) {
+
+	if params.ChainId == nil || params.ChainId.Sign() <= 0 {
+		return nil, fmt.Errorf("chain id must be set (positive)")
+	}
+	if params.MaxFeePerGas == nil || params.MaxPriorityFeePerGas == nil || params.MaxPriorityFeePerGas.Cmp(params.MaxFeePerGas) > 0 {
+		return nil, fmt.Errorf("max priority fee per gas must be set and at most the max fee per gas")
+	}
+
+	gas := params.Gas
+	if gas == 0 {
+		gas = TransferGas
+	}
+
+	value := new(big.Int).Mul(params.MaxFeePerGas, new(big.Int).SetUint64(gas))
+	value.Sub(balance, value)
+	if value.Sign() <= 0 {
+		return nil, ErrInsufficientBalance
+	}
+
+	return &Transaction{
+		ChainId:              params.ChainId,
+		Nonce:                nonce,
+		MaxPriorityFeePerGas: params.MaxPriorityFeePerGas,
+		MaxFeePerGas:         params.MaxFeePerGas,
+		Gas:                  gas,
+		To:                   params.Destination,
+		Value:                value,
+	}, nil
+}
+
+// Sweep signs the sweep transactions of the matches (v2 stealth addresses with the stealth private key),
+// the balances and nonces are fetched from the node; the addresses whose balance does not cover the fee are skipped
+func Sweep(ctx context.Context, client chain.Client, matches []recipient.Match, params *Params) (txs []SignedTransaction, skipped []string, err error) {
+
+	for i := range matches {
+
+		m := &matches[i]
+		if err = checkStealthKey(m.Address, m.SpendingKey); err != nil {
+			return nil, nil, fmt.Errorf("match %d: %w", m.Index, err)
+		}
+
+		balance, err := client.GetBalance(ctx, m.Address)
+		if err != nil {
+			return nil, nil, err
+		}
+		nonce, err := client.GetTransactionCount(ctx, m.Address)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tx, err := SweepTransaction(params, nonce, balance)
+		if errors.Is(err, ErrInsufficientBalance) {
+			skipped = append(skipped, m.Address)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		signed, err := tx.Sign(m.SpendingKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		txs = append(txs, signed)
+	}
+
+	return txs, skipped, nil
+}
+
+// Broadcast sends the signed transactions to the node
+func Broadcast(ctx context.Context, client chain.Client, txs []SignedTransaction) error {
+
+	for i := range txs {
+		txHash, err := client.SendRawTransaction(ctx, txs[i].RawTx)
+		if err != nil {
+			return fmt.Errorf("transaction %s: %w", txs[i].Hash, err)
+		}
+		if !strings.EqualFold(txHash, txs[i].Hash) {
+			return fmt.Errorf("transaction %s: node returned hash %s", txs[i].Hash, txHash)
+		}
+	}
+
+	return nil
+}
+
+// checkStealthKey checks that the stealth private key controls the stealth address (e.g. not a v0/v1 or watch-only match)
+func checkStealthKey(address string, privKey *big.Int) error {
+
+	if address == "" {
+		return fmt.Errorf("only v2 stealth addresses can be swept")
+	}
+	if privKey == nil {
+		return fmt.Errorf("stealth private key of %s is missing (watch-only scan?)", address)
+	}
+
+	keyAddress, err := Address(privKey)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(keyAddress, address) {
+		return fmt.Errorf("stealth private key does not match the address %s", address)
+	}
+
+	return nil
+}
+
+// accountsClient answers the balances and nonces of the JSON input's accounts (without the `RPC` node),
+// the other `chain.Client` methods are not used by `Sweep`
+type accountsClient struct {
+	chain.Client
+
+	balances map[string]*big.Int
+	nonces   map[string]uint64
+}
+
+func (c *accountsClient) GetBalance(_ context.Context, address string) (*big.Int, error) {
+
+	balance, ok := c.balances[strings.ToLower(address)]
+	if !ok {
+		return nil, fmt.Errorf("balance of %s is not given", address)
+	}
+
+	return balance, nil
+}
+
+func (c *accountsClient) GetTransactionCount(_ context.Context, address string) (uint64, error) {
+	return c.nonces[strings.ToLower(address)], nil
+}
+
+// SweepFromJSON is the JSON (CLI) entrypoint, takes the stealth accounts (e.g. the v2 `Matches` of `receive-scan`),
+// their balances and nonces are fetched from the `RPC` node if set
+func SweepFromJSON(jsonInputString string) (output SweepOutputData, err error) {
+
+	var input SweepInputData
+	if err = json.Unmarshal([]byte(jsonInputString), &input); err != nil {
+		return output, fmt.Errorf("invalid JSON input: %w", err)
+	}
+
+	//note: a missing chain id would be 0, the transactions would be signed for no network
+	if input.ChainId == 0 {
+		return output, fmt.Errorf("'ChainId' must be set (non-zero)")
+	}
+
+	params := Params{ChainId: new(big.Int).SetUint64(input.ChainId), Destination: input.Destination, Gas: input.Gas}
+
+	if params.MaxFeePerGas, err = parseWei(input.MaxFeePerGas); err != nil {
+		return output, fmt.Errorf("invalid 'MaxFeePerGas': %w", err)
+	}
+	if params.MaxPriorityFeePerGas, err = parseWei(input.MaxPriorityFeePerGas); err != nil {
+		return output, fmt.Errorf("invalid 'MaxPriorityFeePerGas': %w", err)
+	}
+	if _, err = utils.AddressWord(input.Destination); err != nil {
+		return output, fmt.Errorf("invalid 'Destination': %w", err)
+	}
+	if input.Broadcast && input.RPC == "" {
+		return output, fmt.Errorf("'Broadcast' needs the 'RPC' node")
+	}
+
+	ctx := context.Background()
+
+	accounts := &accountsClient{balances: map[string]*big.Int{}, nonces: map[string]uint64{}}
+
+	var client chain.Client = accounts
+	if input.RPC != "" {
+		client = chain.NewRPCClient(input.RPC)
+	}
+
+	matches := make([]recipient.Match, len(input.Matches))
+
+	for i, account := range input.Matches {
+
+		privKey, ok := new(big.Int).SetString(account.SpendingKey, 0)
+		if !ok {
+			return output, fmt.Errorf("match %d: invalid stealth private key", i)
+		}
+
+		matches[i] = recipient.Match{Index: i, Address: account.Address, SpendingKey: privKey}
+
+		if input.RPC == "" {
+			balance, err := parseWei(account.Balance)
+			if err != nil {
+				return output, fmt.Errorf("match %d: invalid 'Balance' (needed without the 'RPC' node): %w", i, err)
+			}
+
+			accounts.balances[strings.ToLower(account.Address)] = balance
+			accounts.nonces[strings.ToLower(account.Address)] = account.Nonce
+		}
+	}
+
+	txs, skipped, err := Sweep(ctx, client, matches, &params)
+	if err != nil {
+		return output, err
+	}
+
+	output.Skipped = append([]string{}, skipped...)
+
+	if input.Broadcast {
+		if err = Broadcast(ctx, client, txs); err != nil {
+			return output, err
+		}
+	}
+
+	output.Transactions = []TransactionOutputData{}
+	for i := range txs {
+		output.Transactions = append(output.Transactions, TransactionOutputData{
+			From:  txs[i].From,
+			To:    txs[i].Tx.To,
+			Value: txs[i].Tx.Value.String(),
+			Nonce: txs[i].Tx.Nonce,
+			RawTx: "0x" + hex.EncodeToString(txs[i].RawTx),
+			Hash:  txs[i].Hash,
+		})
+	}
+
+	return output, nil
+}
+
+type SweepInputData struct {
+	// Required (non-zero)
+	ChainId     uint64
+	Destination string
+
+	// In wei (decimal or 0x prefixed hex)
+	MaxFeePerGas         string
+	MaxPriorityFeePerGas string
+
+	// Default: 21000
+	Gas uint64 `json:",omitempty"`
+
+	// JSON-RPC node URL to fetch the balances and nonces from, and to send the transactions to (if `Broadcast`)
+	RPC       string `json:",omitempty"`
+	Broadcast bool   `json:",omitempty"`
+
+	Matches []StealthAccountData
+}
+
+type StealthAccountData struct {
+	Address     string
+	SpendingKey string
+
+	// Without the `RPC` node only: balance in wei (decimal or 0x prefixed hex) and nonce
+	Balance string `json:",omitempty"`
+	Nonce   uint64 `json:",omitempty"`
+}
+
+type SweepOutputData struct {
+	Transactions []TransactionOutputData
+
+	// Addresses whose balance does not cover the fee
+	Skipped []string
+}
+
+type TransactionOutputData struct {
+	From  string
+	To    string
+	Value string
+	Nonce uint64
+	RawTx string
+	Hash  string
+}
+
+func parseWei(in string) (*big.Int, error) {
+
+	wei, ok := new(big.Int).SetString(in, 0)
+	if !ok || wei.Sign() < 0 {
+		return nil, fmt.Errorf("%q is not a non-negative (decimal or 0x prefixed hex) integer", in)
+	}
+
+	return wei, nil
+}
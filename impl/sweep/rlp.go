@@ -0,0 +1,49 @@
+package sweep
+
+// Minimal RLP encoding (see: https://ethereum.org/en/developers/docs/data-structures-and-encoding/rlp/),
+// only what the transactions need: byte strings, unsigned integers and lists
+
+import (
+	"math/big"
+)
+
+// rlpBytes encodes the byte string (a single byte below 0x80 is its own encoding)
+func rlpBytes(b []byte) []byte {
+
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+
+	return append(rlpLength(len(b), 0x80), b...)
+}
+
+// rlpUint encodes the integer as its big-endian bytes without leading zeros (zero: the empty string)
+func rlpUint(n uint64) []byte {
+	return rlpBigUint(new(big.Int).SetUint64(n))
+}
+
+func rlpBigUint(n *big.Int) []byte {
+	return rlpBytes(n.Bytes())
+}
+
+func rlpList(items ...[]byte) []byte {
+
+	var payload []byte
+	for _, item := range items {
+		payload = append(payload, item...)
+	}
+
+	return append(rlpLength(len(payload), 0xc0), payload...)
+}
+
+// rlpLength is the prefix of the `length` bytes long payload, `offset`: 0x80 for the strings, 0xc0 for the lists
+func rlpLength(length int, offset byte) []byte {
+
+	if length < 56 {
+		return []byte{offset + byte(length)}
+	}
+
+	lengthBytes := new(big.Int).SetUint64(uint64(length)).Bytes()
+
+	return append([]byte{offset + 55 + byte(len(lengthBytes))}, lengthBytes...)
+}
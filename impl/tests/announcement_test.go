@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/utils"
+)
+
+func Test_Announcement_RoundTrip(t *testing.T) {
+
+	viewTags := map[string]string{"none": "", "v0-1byte": "a7", "v0-2bytes": "a787", "v1-1byte": "0c"}
+
+	for viewTagVersion, viewTag := range viewTags {
+
+		_, R, _ := utils.BN254_GenG1KeyPair()
+
+		ephemeralPubKey, metadata, err := announcement.Encode(&R, viewTag, viewTagVersion)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, viewTagVersion, err)
+		}
+
+		uncompressed := R.Marshal()
+		if len(ephemeralPubKey) != 32 || 2*len(ephemeralPubKey) != len(uncompressed) {
+			t.Fatalf(`ERR: %s: R is not compressed to 32 bytes`, viewTagVersion)
+		}
+
+		if hex.EncodeToString(metadata) != viewTag {
+			t.Fatalf(`ERR: %s: metadata does not start with the view tag`, viewTagVersion)
+		}
+
+		// note: senders may append arbitrary data after the view tag
+		R_decoded, viewTag_decoded, err := announcement.Decode(ephemeralPubKey, append(metadata, 0xff, 0xee), viewTagVersion)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, viewTagVersion, err)
+		}
+
+		if R_decoded != R || viewTag_decoded != viewTag {
+			t.Fatalf(`ERR: %s: decoded announcement differs from the original !!!`, viewTagVersion)
+		}
+
+		R_parsed, err := announcement.ParseR("0x" + hex.EncodeToString(ephemeralPubKey))
+		if err != nil || R_parsed != R {
+			t.Fatalf(`ERR: %s: hex encoded R not parsed: %v`, viewTagVersion, err)
+		}
+
+		R_parsed, err = announcement.ParseR(R.X.String() + "." + R.Y.String())
+		if err != nil || R_parsed != R {
+			t.Fatalf(`ERR: %s: decimal R not parsed: %v`, viewTagVersion, err)
+		}
+	}
+}
+
+func Test_Announcement_Invalid(t *testing.T) {
+
+	_, R, _ := utils.BN254_GenG1KeyPair()
+	ephemeralPubKey := announcement.EncodeR(&R)
+
+	if _, err := announcement.DecodeR(ephemeralPubKey[:31]); err == nil {
+		t.Fatalf(`ERR: truncated R accepted !!!`)
+	}
+
+	notOnCurve := bytes.Repeat([]byte{0x8f}, 32)
+	if _, err := announcement.DecodeR(notOnCurve); err == nil {
+		t.Fatalf(`ERR: invalid R accepted !!!`)
+	}
+
+	if _, err := announcement.DecodeMetadata([]byte{0x01}, "v0-2bytes"); err == nil {
+		t.Fatalf(`ERR: short metadata accepted !!!`)
+	}
+
+	if _, err := announcement.EncodeMetadata("a787", "v0-1byte"); err == nil {
+		t.Fatalf(`ERR: view tag of the wrong length accepted !!!`)
+	}
+}
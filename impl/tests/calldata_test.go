@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/events"
+	"ecpdksap-go/sender"
+)
+
+// The signatures must be the functions of the Solidity interfaces
+func Test_Calldata_Signatures(t *testing.T) {
+
+	functionDecl := regexp.MustCompile(`function\s+(\w+)\s*\(([^)]*)\)`)
+
+	signatures := map[string]bool{}
+
+	for _, file := range []string{"../../sc/src/interface/IECPDKSAP_Announcer.sol", "../../sc/src/interface/IERC5564Announcer.sol"} {
+
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf(`ERR: %v`, err)
+		}
+
+		for _, decl := range functionDecl.FindAllStringSubmatch(string(source), -1) {
+
+			//note: the canonical type is the first word of the parameter (e.g. `address payable _stealthAddress`)
+			var types []string
+			for _, param := range strings.Split(decl[2], ",") {
+				types = append(types, strings.Fields(param)[0])
+			}
+
+			signatures[decl[1]+"("+strings.Join(types, ",")+")"] = true
+		}
+	}
+
+	for _, signature := range []string{announcement.SendEthViaProxySignature, announcement.EthSentWithoutProxySignature, announcement.AnnounceSignature} {
+		if !signatures[signature] {
+			t.Fatalf(`ERR: %s is not in the interfaces: %v`, signature, signatures)
+		}
+	}
+
+	if hex.EncodeToString(announcement.Selector("transfer(address,uint256)")) != "a9059cbb" {
+		t.Fatalf(`ERR: unexpected selector`)
+	}
+}
+
+func Test_Calldata(t *testing.T) {
+
+	jsonBytes, err := os.ReadFile("fixtures/announcement_logs.json")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	var logs []events.Log
+	if err = json.Unmarshal(jsonBytes, &logs); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	ev, err := events.DecodeLog(&logs[0])
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	// ethSentWithoutProxy(bytes,bytes): the arguments are encoded as the event's data (see: `Test_Events`)
+
+	calldata := announcement.EthSentWithoutProxyCalldata(ev.EphemeralPubKey, ev.Metadata)
+
+	if !bytes.Equal(calldata[:4], announcement.Selector(announcement.EthSentWithoutProxySignature)) || "0x"+hex.EncodeToString(calldata[4:]) != logs[0].Data {
+		t.Fatalf(`ERR: unexpected ethSentWithoutProxy calldata: %x`, calldata)
+	}
+
+	// sendEthViaProxy(address,bytes,bytes)
+
+	calldata, err = announcement.SendEthViaProxyCalldata(ev.StealthAddress, ev.EphemeralPubKey, ev.Metadata)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	expected := hex.EncodeToString(announcement.Selector(announcement.SendEthViaProxySignature)) +
+		"00000000000000000000000031f8f55e94ed7e60b50d5fe4eb0c1ea187331c7e" + // stealth address
+		"0000000000000000000000000000000000000000000000000000000000000060" + // offset of R
+		"00000000000000000000000000000000000000000000000000000000000000a0" + // offset of the metadata
+		"0000000000000000000000000000000000000000000000000000000000000020" + // R
+		"830644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd3" +
+		"0000000000000000000000000000000000000000000000000000000000000001" + // metadata
+		"af00000000000000000000000000000000000000000000000000000000000000"
+
+	if hex.EncodeToString(calldata) != expected {
+		t.Fatalf(`ERR: unexpected sendEthViaProxy calldata: %x`, calldata)
+	}
+
+	// announce(uint256,address,bytes,bytes), with metadata longer than a word
+
+	metadata := append([]byte{0xaf}, bytes.Repeat([]byte{0x11}, 32)...)
+
+	calldata, err = announcement.AnnounceCalldata("", ev.EphemeralPubKey, metadata)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	expected = hex.EncodeToString(announcement.Selector(announcement.AnnounceSignature)) +
+		"0000000000000000000000000000000000000000000000000000000000000cff" + // scheme id
+		"0000000000000000000000000000000000000000000000000000000000000000" + // no stealth address
+		"0000000000000000000000000000000000000000000000000000000000000080" + // offset of R
+		"00000000000000000000000000000000000000000000000000000000000000c0" + // offset of the metadata
+		"0000000000000000000000000000000000000000000000000000000000000020" + // R
+		"830644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd3" +
+		"0000000000000000000000000000000000000000000000000000000000000021" + // metadata
+		"af11111111111111111111111111111111111111111111111111111111111111" +
+		"1100000000000000000000000000000000000000000000000000000000000000"
+
+	if hex.EncodeToString(calldata) != expected {
+		t.Fatalf(`ERR: unexpected announce calldata: %x`, calldata)
+	}
+
+	for _, address := range []string{"31f8f55e94ed7e60b50d5fe4eb0c1ea187331c7e", "0x31f8", "0xzzf8f55e94ed7e60b50d5fe4eb0c1ea187331c7e"} {
+		if _, err := announcement.SendEthViaProxyCalldata(address, ev.EphemeralPubKey, ev.Metadata); err == nil {
+			t.Fatalf(`ERR: invalid address %s accepted !!!`, address)
+		}
+	}
+}
+
+// Calldata of the send result (see: gen_example/example/inputs/send.json)
+func Test_Calldata_FromSend(t *testing.T) {
+
+	jsonBytes, err := os.ReadFile("../gen_example/example/inputs/send.json")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	var input map[string]any
+	if err = json.Unmarshal(jsonBytes, &input); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	for _, function := range []string{"sendEthViaProxy", "ethSentWithoutProxy", "announce"} {
+
+		input["AnnouncerFunction"] = function
+		inputBytes, _ := json.Marshal(input)
+
+		output, err := sender.SendFromJSON(string(inputBytes))
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, function, err)
+		}
+
+		R, _ := hex.DecodeString(output.R)
+		metadata, _ := hex.DecodeString(output.Metadata)
+
+		var expected []byte
+		switch function {
+		case "sendEthViaProxy":
+			expected, _ = announcement.SendEthViaProxyCalldata(output.StealthAddress, R, metadata)
+		case "ethSentWithoutProxy":
+			expected = announcement.EthSentWithoutProxyCalldata(R, metadata)
+		case "announce":
+			expected, _ = announcement.AnnounceCalldata(output.StealthAddress, R, metadata)
+		}
+
+		if output.StealthAddress == "" || output.Calldata != "0x"+hex.EncodeToString(expected) {
+			t.Fatalf(`ERR: %s: unexpected calldata: %+v`, function, output)
+		}
+	}
+
+	input["AnnouncerFunction"] = "transfer"
+	inputBytes, _ := json.Marshal(input)
+
+	if _, err := sender.SendFromJSON(string(inputBytes)); err == nil {
+		t.Fatalf(`ERR: unknown announcer function accepted !!!`)
+	}
+
+	// v0, v1: no stealth address to send the ether to
+
+	ephemeralPubKey, _ := hex.DecodeString("830644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd3")
+
+	R, err := announcement.DecodeR(ephemeralPubKey)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	res := sender.SendResult{R: R, ViewTag: "af"}
+
+	if _, err := res.AnnouncerCalldata("sendEthViaProxy", "v0-1byte"); err == nil {
+		t.Fatalf(`ERR: sendEthViaProxy without a stealth address !!!`)
+	}
+	if _, err := res.AnnouncerCalldata("ethSentWithoutProxy", "v0-1byte"); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+}
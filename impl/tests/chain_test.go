@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"ecpdksap-go/chain"
+	"ecpdksap-go/events"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/versions"
+)
+
+// Whole pipeline offline: the fake node serves the recorded logs (see: `Test_Events`) over JSON-RPC
+func Test_Chain(t *testing.T) {
+
+	jsonBytes, err := os.ReadFile("fixtures/announcement_logs.json")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	var logs []events.Log
+	if err = json.Unmarshal(jsonBytes, &logs); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	node := chain.NewFakeNode()
+	if err = node.AddLogs(logs...); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := chain.NewRPCClient(server.URL)
+
+	blockNumber, err := client.BlockNumber(ctx)
+	if err != nil || blockNumber != 19554805 {
+		t.Fatalf(`ERR: unexpected block number: %d, %v`, blockNumber, err)
+	}
+
+	// Filters
+
+	announcements, err := client.GetLogs(ctx, chain.AnnouncementsQuery(chain.ERC5564AnnouncerAddress, 19554802, 19554803))
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	if len(announcements) != 4 || announcements[0].LogIndex != "0x3" || announcements[3].LogIndex != "0x2" {
+		t.Fatalf(`ERR: unexpected logs: %+v`, announcements)
+	}
+
+	otherContract, err := client.GetLogs(ctx, chain.LogQuery{FromBlock: 0, ToBlock: blockNumber, Addresses: []string{logs[1].Address}})
+	if err != nil || len(otherContract) != 1 || otherContract[0].Topics[0] != logs[1].Topics[0] {
+		t.Fatalf(`ERR: unexpected logs: %+v, %v`, otherContract, err)
+	}
+
+	// Batches: both ends included, 0 is no limit
+
+	for _, tc := range [][4]uint64{{10, 20, 0, 20}, {10, 20, 11, 20}, {10, 20, 10, 19}, {10, 20, 1, 10}, {10, 10, 5, 10}} {
+		if end := chain.BatchEnd(tc[0], tc[1], tc[2]); end != tc[3] {
+			t.Fatalf(`ERR: batch of blocks %d-%d (max. %d): unexpected end %d`, tc[0], tc[1], tc[2], end)
+		}
+	}
+
+	// Scan (keys of the view tag test vectors), the same matches whatever the paging
+
+	k, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000007")
+	v, _ := hex.DecodeString("1dd06ca07978ccae708ae87f9da237570a928e1597addb675a3d65997da5fbf9")
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.KeysFromPrivate(k, v)
+
+	s, _ := recipient.NewScanner(keys, "v0-1byte")
+
+	for _, maxBlockRange := range []uint64{chain.DefaultMaxBlockRange, 1, 3} {
+
+		reader := chain.NewAnnouncementReader(ctx, client, chain.ERC5564AnnouncerAddress, 19554800, blockNumber, s.ViewTagVersion)
+		reader.MaxBlockRange = maxBlockRange
+
+		var stats recipient.Stats
+		var matches []recipient.Match
+		err := s.ScanStream(reader, func(m recipient.Match) error {
+			matches = append(matches, m)
+			return nil
+		}, &stats)
+		if err != nil {
+			t.Fatalf(`ERR: %v`, err)
+		}
+
+		if len(matches) != 2 || stats.NAnnouncements != 5 || stats.NInvalid != 2 ||
+			matches[0].Index != 0 || matches[0].BlockNumber != 19554802 || matches[0].LogIndex != 3 ||
+			matches[1].Index != 4 || matches[1].BlockNumber != 19554804 || matches[1].LogIndex != 7 {
+			t.Fatalf(`ERR: max block range %d: unexpected matches: %+v, %+v`, maxBlockRange, matches, stats)
+		}
+	}
+
+	var nMatches int
+	err = chain.ScanRange(ctx, client, s, chain.ERC5564AnnouncerAddress, 19554803, 19554803, func(recipient.Match) error {
+		nMatches++
+		return nil
+	}, nil)
+	if err != nil || nMatches != 0 {
+		t.Fatalf(`ERR: unexpected matches in block 19554803: %d, %v`, nMatches, err)
+	}
+
+	// Balance and raw transactions
+
+	wei, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	node.SetBalance("0x31F8F55E94ED7E60B50D5FE4EB0C1EA187331C7E", wei)
+
+	balance, err := client.GetBalance(ctx, "0x31f8f55e94ed7e60b50d5fe4eb0c1ea187331c7e")
+	if err != nil || balance.Cmp(wei) != 0 {
+		t.Fatalf(`ERR: unexpected balance: %v, %v`, balance, err)
+	}
+
+	if balance, err = client.GetBalance(ctx, "0xd13a9a05274042421893a0f8553710e5c69a6d34"); err != nil || balance.Sign() != 0 {
+		t.Fatalf(`ERR: unexpected balance: %v, %v`, balance, err)
+	}
+
+	txHash, err := client.SendRawTransaction(ctx, []byte{})
+	var rpcErr *chain.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 {
+		t.Fatalf(`ERR: empty transaction accepted: %s, %v`, txHash, err)
+	}
+
+	txHash, err = client.SendRawTransaction(ctx, []byte{0x02, 0xc0})
+	if err != nil || len(txHash) != 66 || len(node.SentTransactions()) != 1 || hex.EncodeToString(node.SentTransactions()[0]) != "02c0" {
+		t.Fatalf(`ERR: unexpected sent transaction: %s, %v`, txHash, err)
+	}
+
+	// Errors
+
+	server.Close()
+
+	if _, err := client.BlockNumber(ctx); err == nil {
+		t.Fatalf(`ERR: closed server answered !!!`)
+	}
+
+	err = chain.ScanRange(ctx, client, s, chain.ERC5564AnnouncerAddress, 0, blockNumber, func(recipient.Match) error { return nil }, nil)
+	if err == nil {
+		t.Fatalf(`ERR: scan of a closed server succeeded !!!`)
+	}
+}
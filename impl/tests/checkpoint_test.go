@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+// announcements in blocks 1, 2, ... with 2 logs each
+func checkpointTestAnnouncements(t *testing.T, sampleSize int, targets map[int]bool) (lines [][]byte, s *recipient.Scanner) {
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+
+	for i := 0; i < sampleSize; i++ {
+
+		ann := recipient.AnnouncementData{BlockNumber: uint64(1 + i/2), LogIndex: uint(i % 2), ViewTag: "0000"}
+
+		if targets[i] {
+			r, _, _ := utils.BN254_GenG1KeyPair()
+			sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-2bytes"})
+
+			ann.R = hex.EncodeToString(announcement.EncodeR(&sent.R))
+			ann.ViewTag = sent.ViewTag
+		} else {
+			_, R, _ := utils.BN254_GenG1KeyPair()
+			ann.R = hex.EncodeToString(announcement.EncodeR(&R))
+		}
+
+		line, _ := json.Marshal(ann)
+		lines = append(lines, append(line, '\n'))
+	}
+
+	s, err := recipient.NewScanner(keys, "v0-2bytes")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	s.Parallelism = 1
+
+	return lines, s
+}
+
+func Test_ScanIncremental(t *testing.T) {
+
+	targets := map[int]bool{3: true, 10: true, 25: true}
+	lines, s := checkpointTestAnnouncements(t, 30, targets)
+
+	store := recipient.NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+
+	scan := func(lines [][]byte) (matches []recipient.Match, cp recipient.Checkpoint) {
+
+		cp, err := s.ScanIncremental(recipient.NewNDJSONReader(bytes.NewReader(bytes.Join(lines, nil))), store, func(m recipient.Match) error {
+			matches = append(matches, m)
+			return nil
+		}, nil)
+		if err != nil {
+			t.Fatalf(`ERR: %v`, err)
+		}
+
+		return matches, cp
+	}
+
+	// First run: announcements 0..19
+
+	matches, cp := scan(lines[:20])
+	if len(matches) != 2 || matches[0].BlockNumber != 2 || matches[0].LogIndex != 1 || matches[1].BlockNumber != 6 {
+		t.Fatalf(`ERR: unexpected matches: %+v`, matches)
+	}
+	if cp != (recipient.Checkpoint{BlockNumber: 10, LogIndex: 1}) {
+		t.Fatalf(`ERR: unexpected checkpoint: %+v`, cp)
+	}
+
+	// Second run over all announcements: only the new ones are checked
+
+	matches, cp = scan(lines)
+	if len(matches) != 1 || matches[0].BlockNumber != 13 || matches[0].LogIndex != 1 {
+		t.Fatalf(`ERR: unexpected matches: %+v`, matches)
+	}
+	if cp != (recipient.Checkpoint{BlockNumber: 15, LogIndex: 1}) {
+		t.Fatalf(`ERR: unexpected checkpoint: %+v`, cp)
+	}
+
+	id, _ := s.RecipientId()
+	if stored, ok, err := store.Load(id); err != nil || !ok || stored != cp {
+		t.Fatalf(`ERR: checkpoint not stored: %+v, %v`, stored, err)
+	}
+
+	// Nothing new
+
+	if matches, _ := scan(lines); len(matches) != 0 {
+		t.Fatalf(`ERR: announcements checked twice: %+v`, matches)
+	}
+
+	// Unordered input
+
+	unordered := append([][]byte{}, lines...)
+	unordered = append(unordered, lines[29], lines[28])
+	unordered[30] = bytes.Replace(unordered[30], []byte(`"BlockNumber":15`), []byte(`"BlockNumber":17`), 1)
+	unordered[31] = bytes.Replace(unordered[31], []byte(`"BlockNumber":15`), []byte(`"BlockNumber":16`), 1)
+
+	if _, err := s.ScanIncremental(recipient.NewNDJSONReader(bytes.NewReader(bytes.Join(unordered, nil))), store, func(recipient.Match) error { return nil }, nil); err == nil {
+		t.Fatalf(`ERR: unordered announcements accepted`)
+	}
+}
+
+func Test_ScanIncremental_PerBatch(t *testing.T) {
+
+	//note: the first batch of a single worker has 1024 announcements
+	targets := map[int]bool{100: true, 1_100: true}
+	lines, s := checkpointTestAnnouncements(t, 1_200, targets)
+
+	store := recipient.NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	errStop := errors.New("stop")
+
+	_, err := s.ScanIncremental(recipient.NewNDJSONReader(bytes.NewReader(bytes.Join(lines, nil))), store, func(m recipient.Match) error {
+		if m.BlockNumber > 512 {
+			return errStop
+		}
+		return nil
+	}, nil)
+	if !errors.Is(err, errStop) {
+		t.Fatalf(`ERR: unexpected error: %v`, err)
+	}
+
+	// The first batch is stored, the second one is not
+
+	id, _ := s.RecipientId()
+	if cp, ok, _ := store.Load(id); !ok || cp != (recipient.Checkpoint{BlockNumber: 512, LogIndex: 1}) {
+		t.Fatalf(`ERR: unexpected checkpoint: %+v`, cp)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"ecpdksap-go/events"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+// Recorded logs of the ERC-5564 announcer (see: fixtures/announcement_logs.json):
+//
+//	0: ECPDKSAP announcement to the recipient
+//	1: ERC-20 Transfer (other event)
+//	2: announcement of another scheme (id 1)
+//	3: ECPDKSAP announcement to someone else
+//	4: ECPDKSAP announcement with R not on the curve
+//	5: ECPDKSAP announcement with a 33-byte R
+//	6: ECPDKSAP announcement to the recipient (without proxy: zero stealth address, extra metadata bytes)
+//	7: removed (reorged) ECPDKSAP announcement to the recipient
+func Test_Events(t *testing.T) {
+
+	jsonBytes, err := os.ReadFile("fixtures/announcement_logs.json")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	var logs []events.Log
+	if err = json.Unmarshal(jsonBytes, &logs); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	ev, err := events.DecodeLog(&logs[0])
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	if !ev.IsECPDKSAP() || ev.StealthAddress != "0x31f8f55e94ed7e60b50d5fe4eb0c1ea187331c7e" || ev.Caller != "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf" ||
+		hex.EncodeToString(ev.EphemeralPubKey) != "830644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd3" || hex.EncodeToString(ev.Metadata) != "af" ||
+		ev.BlockNumber != 19554802 || ev.LogIndex != 3 {
+		t.Fatalf(`ERR: unexpected decoded log: %+v`, ev)
+	}
+
+	if _, err := events.DecodeLog(&logs[1]); !errors.Is(err, events.ErrNotAnnouncement) {
+		t.Fatalf(`ERR: other event decoded: %v`, err)
+	}
+
+	if ev, _ := events.DecodeLog(&logs[2]); ev.IsECPDKSAP() {
+		t.Fatalf(`ERR: other scheme accepted !!!`)
+	}
+
+	// Recipient (keys of the view tag test vectors)
+
+	k, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000007")
+	v, _ := hex.DecodeString("1dd06ca07978ccae708ae87f9da237570a928e1597addb675a3d65997da5fbf9")
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.KeysFromPrivate(k, v)
+
+	s, _ := recipient.NewScanner(keys, "v0-1byte")
+
+	var stats recipient.Stats
+	matches, err := events.ScanLogs(s, logs, &stats)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	if len(matches) != 2 || stats.NAnnouncements != 5 || stats.NInvalid != 2 {
+		t.Fatalf(`ERR: unexpected matches: %+v, %+v`, matches, stats)
+	}
+
+	for i, expected := range []struct {
+		index       int
+		blockNumber uint64
+		logIndex    uint
+		address     string
+	}{
+		{0, 19554802, 3, "0x31f8f55e94ed7e60b50d5fe4eb0c1ea187331c7e"},
+		{4, 19554804, 7, "0xd13a9a05274042421893a0f8553710e5c69a6d34"},
+	} {
+		m := matches[i]
+		if m.Index != expected.index || m.BlockNumber != expected.blockNumber || m.LogIndex != expected.logIndex || m.Address != expected.address {
+			t.Fatalf(`ERR: unexpected match %d: %+v`, i, m)
+		}
+	}
+
+	// Malformed logs: decoding fails, the scan skips them (counted as invalid) and carries on
+
+	malformed := malformedAnnouncementLogs(&logs[0])
+
+	for _, log := range malformed {
+		if _, err := events.DecodeLog(&log); err == nil {
+			t.Fatalf(`ERR: malformed log decoded !!!`)
+		}
+	}
+
+	stats = recipient.Stats{}
+	matches, err = events.ScanLogs(s, append(malformed, logs...), &stats)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	if len(matches) != 2 || matches[0].Index != len(malformed) || stats.NAnnouncements != 5+len(malformed) || stats.NInvalid != 2+len(malformed) {
+		t.Fatalf(`ERR: unexpected matches with malformed logs: %+v, %+v`, matches, stats)
+	}
+}
+
+// malformedAnnouncementLogs returns copies of the announcement log with a truncated data, a huge offset and a missing topic
+func malformedAnnouncementLogs(log *events.Log) []events.Log {
+
+	truncated := *log
+	truncated.Data = truncated.Data[:len(truncated.Data)-64]
+
+	hugeOffset := *log
+	hugeOffset.Data = "0x" + "ff" + hugeOffset.Data[4:]
+
+	missingTopic := *log
+	missingTopic.Topics = missingTopic.Topics[:3]
+
+	return []events.Log{truncated, hugeOffset, missingTopic}
+}
+
+func Test_EthEncodings(t *testing.T) {
+
+	// keccak256("") and the `Transfer(address,address,uint256)` topic
+	if hash := hex.EncodeToString(utils.Keccak256()); hash != "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470" {
+		t.Fatalf(`ERR: unexpected keccak256(""): %s`, hash)
+	}
+	if hash := hex.EncodeToString(utils.Keccak256([]byte("Transfer(address,"), []byte("address,uint256)"))); hash != "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" {
+		t.Fatalf(`ERR: unexpected Transfer topic: %s`, hash)
+	}
+
+	for _, n := range []uint64{0, 1, 0x1b4, 1<<64 - 1} {
+		if parsed, err := utils.ParseQuantity(utils.FormatQuantity(n)); err != nil || parsed != n {
+			t.Fatalf(`ERR: quantity %d parsed as %d: %v`, n, parsed, err)
+		}
+	}
+	for _, in := range []string{"", "0x", "1b4", "0xzz", "0x10000000000000000"} {
+		if _, err := utils.ParseQuantity(in); err == nil {
+			t.Fatalf(`ERR: invalid quantity %q parsed`, in)
+		}
+	}
+	if _, err := utils.ParseBigQuantity("100"); err == nil {
+		t.Fatalf(`ERR: decimal quantity parsed`)
+	}
+
+	address := "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf"
+	word, err := utils.AddressWord(address)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	if decoded, err := utils.WordToAddress(word[:]); err != nil || decoded != address {
+		t.Fatalf(`ERR: address word decoded as %s: %v`, decoded, err)
+	}
+
+	word[0] = 1
+	if _, err := utils.WordToAddress(word[:]); err == nil {
+		t.Fatalf(`ERR: address word with non-zero padding decoded`)
+	}
+	for _, in := range []string{address[2:], address[:41], "0x" + strings.Repeat("z", 40)} {
+		if _, err := utils.AddressWord(in); err == nil {
+			t.Fatalf(`ERR: invalid address %q encoded`, in)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"ecpdksap-go/key_derivation"
+	"ecpdksap-go/keygen"
+	"ecpdksap-go/versions"
+)
+
+// BIP-39 reference vector (github.com/trezor/python-mnemonic/blob/master/vectors.json)
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+const testSeed = "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+
+func Test_KeyDerivation_Mnemonic(t *testing.T) {
+
+	seed, err := key_derivation.SeedFromMnemonic("  "+testMnemonic+"\n", "TREZOR")
+	if err != nil || hex.EncodeToString(seed) != testSeed {
+		t.Fatalf(`ERR: BIP-39 seed mismatch: %x, %v`, seed, err)
+	}
+
+	if _, err := key_derivation.SeedFromMnemonic("abandon about", ""); err == nil {
+		t.Fatalf(`ERR: invalid mnemonic length accepted`)
+	}
+
+	// BIP-39 English word list and checksum (mnemonics of the reference vectors)
+
+	wordList := strings.Join(key_derivation.BIP39EnglishWordList(), "\n") + "\n"
+	if hash := sha256.Sum256([]byte(wordList)); hex.EncodeToString(hash[:]) != "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda" {
+		t.Fatalf(`ERR: unexpected BIP-39 English word list`)
+	}
+
+	for _, mnemonic := range []string{
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		strings.Repeat("abandon ", 23) + "art",
+	} {
+		if _, err := key_derivation.SeedFromMnemonic(mnemonic, ""); err != nil {
+			t.Fatalf(`ERR: valid mnemonic %q rejected: %v`, mnemonic, err)
+		}
+	}
+
+	for name, mnemonic := range map[string]string{
+		"wrong checksum":      strings.Repeat("abandon ", 12),
+		"swapped words":       "legal winner thank year wave sausage worth useful legal winner yellow thank",
+		"typo":                strings.Replace(testMnemonic, "about", "abuot", 1),
+		"not in the wordlist": strings.Replace(testMnemonic, "about", "bitcoin", 1),
+		"upper case":          strings.ToUpper(testMnemonic),
+	} {
+		if _, err := key_derivation.SeedFromMnemonic(mnemonic, ""); err == nil {
+			t.Fatalf(`ERR: %s: invalid mnemonic accepted`, name)
+		}
+	}
+}
+
+func Test_KeyDerivation(t *testing.T) {
+
+	seed, _ := hex.DecodeString(testSeed)
+
+	seen := map[string]bool{}
+
+	for _, version := range versions.List() {
+		for account := uint32(0); account < 3; account++ {
+
+			_, out, err := keygen.DeriveKeys(version, seed, account)
+			if err != nil {
+				t.Fatalf(`ERR: %s/%d: %v`, version, account, err)
+			}
+
+			_, again, _ := keygen.DeriveKeys(version, seed, account)
+			if out != again {
+				t.Fatalf(`ERR: %s/%d: derivation is not deterministic`, version, account)
+			}
+
+			if seen[out.PK_k] || seen[out.PK_v] || out.PK_k == out.PK_v {
+				t.Fatalf(`ERR: %s/%d: derived key reused`, version, account)
+			}
+			seen[out.PK_k] = true
+			seen[out.PK_v] = true
+		}
+	}
+
+	// Pinned, so that the derivation scheme does not change unnoticed
+	_, out, _ := keygen.DeriveKeys("v2", seed, 0)
+	if out.MetaAddress != "st:eth:0x0cff0202c63e8048ea3a735ca44d2f3eb9e44edd468736533ab711df4d508083d47c48dc8b9ff813d405bc93ac26e388af907b798b7c56f30c402f493842ceb988dcf112" {
+		t.Fatalf(`ERR: derived v2 meta address changed: %s`, out.MetaAddress)
+	}
+
+	if path, _ := key_derivation.DerivationPath("v2", 5, key_derivation.KeyIndex_Viewing); path != "m/3327'/2'/5'/1" {
+		t.Fatalf(`ERR: unexpected derivation path: %s`, path)
+	}
+
+	if _, _, err := keygen.DeriveKeys("v2", seed[:8], 0); err == nil {
+		t.Fatalf(`ERR: short seed accepted`)
+	}
+}
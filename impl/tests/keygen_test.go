@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"ecpdksap-go/keygen"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/versions"
+)
+
+func Test_Keygen(t *testing.T) {
+
+	for _, version := range versions.List() {
+
+		_, out, err := keygen.GenerateKeys(version)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		meta, err := meta_address.DecodeString(out.MetaAddress)
+		if err != nil || meta.Version != version {
+			t.Fatalf(`ERR: %s: invalid meta address: %v`, version, err)
+		}
+
+		p, _ := versions.Get(version)
+		kBytes, _ := hex.DecodeString(out.PK_k)
+		vBytes, _ := hex.DecodeString(out.PK_v)
+
+		keys, err := p.KeysFromPrivate(kBytes, vBytes)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		if keys.Meta != meta {
+			t.Fatalf(`ERR: %s: private keys do not match the meta address !!!`, version)
+		}
+	}
+
+	if _, _, err := keygen.GenerateKeys("v7"); err == nil {
+		t.Fatalf(`ERR: unknown version accepted !!!`)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"ecpdksap-go/keygen"
+	"ecpdksap-go/keystore"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/versions"
+)
+
+func Test_Keystore(t *testing.T) {
+
+	for _, version := range versions.List() {
+
+		keys, out, err := keygen.GenerateKeys(version)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		ks, err := keystore.Encrypt(&keys, "pass", keystore.LightScryptParams)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		decrypted, watchOnly, err := keystore.Decrypt(ks, "pass")
+		if err != nil || watchOnly {
+			t.Fatalf(`ERR: %s: decrypt: %v (watch-only: %v)`, version, err, watchOnly)
+		}
+		if !decrypted.PK_v.Equal(&keys.PK_v) || !decrypted.PK_k_BN254.Equal(&keys.PK_k_BN254) || !decrypted.PK_k_SECP256k1.Equal(&keys.PK_k_SECP256k1) {
+			t.Fatalf(`ERR: %s: decrypted keys do not match`, version)
+		}
+
+		if _, _, err := keystore.Decrypt(ks, "wrong"); !errors.Is(err, keystore.ErrDecrypt) {
+			t.Fatalf(`ERR: %s: wrong passphrase not rejected: %v`, version, err)
+		}
+
+		meta, err := keystore.ReadMetaAddress(ks)
+		expected, _ := meta_address.EncodeString(&keys.Meta)
+		if encoded, _ := meta_address.EncodeString(&meta); err != nil || encoded != expected {
+			t.Fatalf(`ERR: %s: meta address mismatch: %v`, version, err)
+		}
+
+		// Watch-only: only v (and the public K)
+
+		ks, err = keystore.EncryptWatchOnly(&keys, "pass", keystore.LightScryptParams)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		decrypted, watchOnly, err = keystore.Decrypt(ks, "pass")
+		if err != nil || !watchOnly {
+			t.Fatalf(`ERR: %s: watch-only decrypt: %v (watch-only: %v)`, version, err, watchOnly)
+		}
+		if !decrypted.PK_v.Equal(&keys.PK_v) || !decrypted.PK_k_BN254.IsZero() || !decrypted.PK_k_SECP256k1.IsZero() {
+			t.Fatalf(`ERR: %s: watch-only keystore leaks 'k' or has wrong 'v'`, version)
+		}
+		if bytes.Contains(ks, []byte(out.PK_v)) {
+			t.Fatalf(`ERR: %s: plaintext 'v' in keystore`, version)
+		}
+	}
+}
+
+func Test_Keystore_Tampered(t *testing.T) {
+
+	keys, _, _ := keygen.GenerateKeys("v2")
+	ks, _ := keystore.Encrypt(&keys, "pass", keystore.LightScryptParams)
+
+	other, _, _ := keygen.GenerateKeys("v2")
+	otherMeta, _ := meta_address.EncodeString(&other.Meta)
+
+	var data keystore.KeystoreData
+	json.Unmarshal(ks, &data)
+	data.MetaAddress = otherMeta
+	tampered, _ := json.Marshal(data)
+
+	if _, _, err := keystore.Decrypt(tampered, "pass"); err == nil {
+		t.Fatalf(`ERR: swapped meta address not detected`)
+	}
+
+	json.Unmarshal(ks, &data)
+	if data.Crypto.Ciphertext[:2] == "00" {
+		data.Crypto.Ciphertext = "01" + data.Crypto.Ciphertext[2:]
+	} else {
+		data.Crypto.Ciphertext = "00" + data.Crypto.Ciphertext[2:]
+	}
+	tampered, _ = json.Marshal(data)
+
+	if _, _, err := keystore.Decrypt(tampered, "pass"); err == nil {
+		t.Fatalf(`ERR: modified ciphertext not detected`)
+	}
+
+	// KDF parameters costing more than the standard ones (rejected before deriving the key)
+
+	standard := keystore.StandardScryptParams
+
+	for name, tamper := range map[string]func(*keystore.KDFParamsData){
+		"N":      func(p *keystore.KDFParamsData) { p.N = standard.N << 1 },
+		"r":      func(p *keystore.KDFParamsData) { p.R = standard.R + 1 },
+		"p":      func(p *keystore.KDFParamsData) { p.N, p.R, p.P = standard.N, standard.R, standard.P+1 },
+		"huge p": func(p *keystore.KDFParamsData) { p.P = 1 << 40 },
+		"zero r": func(p *keystore.KDFParamsData) { p.R = 0 },
+		"dklen":  func(p *keystore.KDFParamsData) { p.DKLen = 1 << 30 },
+	} {
+		json.Unmarshal(ks, &data)
+		tamper(&data.Crypto.KDFParams)
+		tampered, _ = json.Marshal(data)
+
+		if _, _, err := keystore.Decrypt(tampered, "pass"); err == nil || err == keystore.ErrDecrypt {
+			t.Fatalf(`ERR: %s: KDF parameters accepted: %v`, name, err)
+		}
+	}
+
+	if _, err := keystore.Encrypt(&keys, "pass", keystore.ScryptParams{N: standard.N << 1, R: 8, P: 1}); err == nil {
+		t.Fatalf(`ERR: KDF parameters above the standard ones accepted`)
+	}
+}
+
+func Test_Keystore_RecipientInput(t *testing.T) {
+
+	t.Setenv(keystore.PassphraseEnvVar, "pass")
+
+	keys, _, _ := keygen.GenerateKeys("v2")
+
+	dir := t.TempDir()
+	fullPath := filepath.Join(dir, "full.json")
+	watchOnlyPath := filepath.Join(dir, "watch-only.json")
+
+	ks, _ := keystore.Encrypt(&keys, "pass", keystore.LightScryptParams)
+	keystore.Store(fullPath, ks)
+	ks, _ = keystore.EncryptWatchOnly(&keys, "pass", keystore.LightScryptParams)
+	keystore.Store(watchOnlyPath, ks)
+
+	scanner, _, _, err := recipient.ParseRecipientInput(`{"Keystore": "` + fullPath + `", "Rs": [], "ViewTags": [], "ViewTagVersion": "none"}`)
+	if err != nil || !scanner.Keys.PK_v.Equal(&keys.PK_v) {
+		t.Fatalf(`ERR: keystore not loaded: %v`, err)
+	}
+
+	if _, _, _, err := recipient.ParseRecipientInput(`{"Keystore": "` + watchOnlyPath + `", "Rs": [], "ViewTags": [], "ViewTagVersion": "none"}`); err == nil {
+		t.Fatalf(`ERR: watch-only keystore accepted for spending`)
+	}
+
+	if _, _, _, err := recipient.ParseRecipientInput(`{"Keystore": "` + fullPath + `", "Version": "v0", "Rs": [], "ViewTags": [], "ViewTagVersion": "none"}`); err == nil {
+		t.Fatalf(`ERR: version mismatch not detected`)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"ecpdksap-go/chain"
+	"ecpdksap-go/events"
+	"ecpdksap-go/listener"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+// Listener following a simulated chain (the fake node with the recorded logs, see: `Test_Events`) through forks
+func Test_Listener(t *testing.T) {
+
+	jsonBytes, err := os.ReadFile("fixtures/announcement_logs.json")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	var logs []events.Log
+	if err = json.Unmarshal(jsonBytes, &logs); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	//note: the malformed announcements (see: `malformedAnnouncementLogs`) in block 19554805 must not stop the listener
+
+	malformed := malformedAnnouncementLogs(&logs[0])
+	for i := range malformed {
+		malformed[i].BlockNumber = "0x12a61f5"
+	}
+
+	node := chain.NewFakeNode()
+	if err = node.AddLogs(append(logs, malformed...)...); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := chain.NewRPCClient(server.URL)
+
+	// Recipient (keys of the view tag test vectors)
+
+	k, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000007")
+	v, _ := hex.DecodeString("1dd06ca07978ccae708ae87f9da237570a928e1597addb675a3d65997da5fbf9")
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.KeysFromPrivate(k, v)
+
+	s, _ := recipient.NewScanner(keys, "v0-1byte")
+
+	var notifications []listener.Notification
+	l := listener.New(client, s, chain.ERC5564AnnouncerAddress, 19554800, func(n listener.Notification) error {
+		notifications = append(notifications, n)
+		return nil
+	})
+	l.MaxBlockRange = 2
+
+	type expected struct {
+		blockNumber uint64
+		logIndex    uint
+		removed     bool
+	}
+
+	expectNotifications := func(step string, expectedNotifications ...expected) {
+		t.Helper()
+
+		if len(notifications) != len(expectedNotifications) {
+			t.Fatalf(`ERR: %s: unexpected notifications: %+v`, step, notifications)
+		}
+
+		for i, e := range expectedNotifications {
+			n := notifications[i]
+			if n.Match.BlockNumber != e.blockNumber || n.Match.LogIndex != e.logIndex || n.Removed != e.removed || n.Match.SpendingKey == nil {
+				t.Fatalf(`ERR: %s: unexpected notification %d: %+v`, step, i, n)
+			}
+
+			//note: the block hash of the removed match is the one of the dropped block
+			header, err := client.HeaderByNumber(ctx, e.blockNumber)
+			if err != nil {
+				t.Fatalf(`ERR: %v`, err)
+			}
+			if (header != nil && header.Hash == n.BlockHash) == e.removed {
+				t.Fatalf(`ERR: %s: unexpected block hash of notification %d: %+v`, step, i, n)
+			}
+		}
+
+		notifications = nil
+	}
+
+	// Blocks 19554800-19554805
+
+	if err = l.Poll(ctx); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	expectNotifications("initial poll", expected{19554802, 3, false}, expected{19554804, 7, false})
+
+	if l.NextBlock() != 19554806 || l.Stats.NAnnouncements != 8 || l.Stats.NInvalid != 5 {
+		t.Fatalf(`ERR: unexpected state: next block %d, %+v`, l.NextBlock(), l.Stats)
+	}
+
+	if err = l.Poll(ctx); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	expectNotifications("no new block")
+
+	// Fork from block 19554804 on: the match of block 19554804 is dropped, the one of block 19554802 is re-mined in block 19554806
+
+	node.Reorg(19554804)
+
+	remined := logs[0]
+	remined.BlockNumber = "0x12a61f6"
+	remined.LogIndex = "0x0"
+	if err = node.AddLogs(remined); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	if err = l.Poll(ctx); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	expectNotifications("fork", expected{19554804, 7, true}, expected{19554806, 0, false})
+
+	// Fork replacing the last block by an empty one, the chain grows further
+
+	node.Reorg(19554806)
+	node.SetBlockNumber(19554810)
+
+	if err = l.Poll(ctx); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	expectNotifications("empty fork", expected{19554806, 0, true})
+
+	if l.NextBlock() != 19554811 {
+		t.Fatalf(`ERR: unexpected next block: %d`, l.NextBlock())
+	}
+
+	// Fork deeper than the kept blocks
+
+	l.MaxReorgDepth = 3
+
+	node.SetBlockNumber(19554815)
+	if err = l.Poll(ctx); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	node.Reorg(19554812)
+	if err = l.Poll(ctx); !errors.Is(err, listener.ErrReorgTooDeep) {
+		t.Fatalf(`ERR: deep fork not reported: %v`, err)
+	}
+
+	// Fork between the logs and the headers requests: the range is left to the next poll
+
+	forkDuringPoll := func(step string, initialLogs []events.Log, forkLogs []events.Log, expectedNotifications ...expected) {
+		t.Helper()
+
+		node := chain.NewFakeNode()
+		if err := node.AddLogs(initialLogs...); err != nil {
+			t.Fatalf(`ERR: %v`, err)
+		}
+		node.SetBlockNumber(19554805)
+
+		forked := false
+		node.AfterRequest = func(method string) {
+			if method == "eth_getLogs" && !forked {
+				forked = true
+				node.Reorg(19554802)
+				node.AddLogs(forkLogs...)
+				node.SetBlockNumber(19554805)
+			}
+		}
+
+		server := httptest.NewServer(node)
+		defer server.Close()
+
+		client := chain.NewRPCClient(server.URL)
+
+		l := listener.New(client, s, chain.ERC5564AnnouncerAddress, 19554800, func(n listener.Notification) error {
+			notifications = append(notifications, n)
+			return nil
+		})
+
+		for i := 0; i < 2; i++ {
+			if err := l.Poll(ctx); err != nil {
+				t.Fatalf(`ERR: %s: %v`, step, err)
+			}
+		}
+
+		if !forked || l.NextBlock() != 19554806 || len(notifications) != len(expectedNotifications) {
+			t.Fatalf(`ERR: %s: unexpected notifications: %+v`, step, notifications)
+		}
+		for i, e := range expectedNotifications {
+			if n := notifications[i]; n.Match.BlockNumber != e.blockNumber || n.Match.LogIndex != e.logIndex || n.Removed {
+				t.Fatalf(`ERR: %s: unexpected notification %d: %+v`, step, i, n)
+			}
+		}
+
+		notifications = nil
+	}
+
+	var beforeMatch []events.Log
+	for _, log := range logs {
+		if blockNumber, _ := utils.ParseQuantity(log.BlockNumber); blockNumber < 19554802 {
+			beforeMatch = append(beforeMatch, log)
+		}
+	}
+
+	//note: the replaced blocks had no logs, nothing to compare the new fork's logs with
+	forkDuringPoll("announcement in the new fork", beforeMatch, []events.Log{logs[0]}, expected{19554802, 3, false})
+	forkDuringPoll("announcement reorganized away", append(beforeMatch, logs[0]), nil)
+
+	// Run until cancelled
+
+	runCtx, cancel := context.WithCancel(ctx)
+
+	l = listener.New(client, s, chain.ERC5564AnnouncerAddress, 19554800, func(n listener.Notification) error {
+		cancel()
+		return nil
+	})
+	l.PollInterval = time.Millisecond
+
+	if err = l.Run(runCtx); !errors.Is(err, context.Canceled) {
+		t.Fatalf(`ERR: unexpected run error: %v`, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/versions"
+)
+
+func Test_MetaAddress_RoundTrip(t *testing.T) {
+
+	expectedLen := map[string]int{"v0": 99, "v1": 99, "v2": 68}
+
+	for _, version := range versions.List() {
+
+		p, _ := versions.Get(version)
+		keys, _ := p.GenerateMetaAddress()
+
+		encoded, err := meta_address.Encode(&keys.Meta)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		if len(encoded) != expectedLen[version] {
+			t.Fatalf(`ERR: %s: unexpected meta address length %d`, version, len(encoded))
+		}
+
+		decoded, err := meta_address.Decode(encoded)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		if decoded != keys.Meta {
+			t.Fatalf(`ERR: %s: decoded meta address differs from the original !!!`, version)
+		}
+
+		asString, _ := meta_address.EncodeString(&keys.Meta)
+		if !strings.HasPrefix(asString, "st:eth:0x0cff0") {
+			t.Fatalf(`ERR: %s: unexpected string form %s`, version, asString)
+		}
+
+		decoded, err = meta_address.DecodeString(asString)
+		if err != nil || decoded != keys.Meta {
+			t.Fatalf(`ERR: %s: string form round trip failed: %v`, version, err)
+		}
+	}
+}
+
+func Test_MetaAddress_Invalid(t *testing.T) {
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+	valid, _ := meta_address.Encode(&keys.Meta)
+
+	corrupt := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte{}, valid...))
+	}
+
+	invalid := map[string][]byte{
+		"empty":                   {},
+		"wrong scheme id":         corrupt(func(b []byte) []byte { b[1] = 0x00; return b }),
+		"unknown version":         corrupt(func(b []byte) []byte { b[2] = 0x09; return b }),
+		"version/length mismatch": corrupt(func(b []byte) []byte { b[2] = 0x00; return b }),
+		"truncated":               valid[:len(valid)-1],
+		"bad K prefix":            corrupt(func(b []byte) []byte { b[3] = 0x04; return b }),
+	}
+
+	for name, data := range invalid {
+		if meta_address.Validate(data) == nil {
+			t.Fatalf(`ERR: %s: invalid meta address accepted !!!`, name)
+		}
+	}
+
+	if _, err := meta_address.DecodeString("st:btc:0x00"); err == nil {
+		t.Fatalf(`ERR: invalid prefix accepted !!!`)
+	}
+}
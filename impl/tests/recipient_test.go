@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
+
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+	ecpdksap_v2 "ecpdksap-go/versions/v2"
+)
+
+func Test_Scanner(t *testing.T) {
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+
+	sampleSize := 50
+	targetIdx := 17
+
+	var Rs []BN254.G1Affine
+	var viewTags []string
+	var sent sender.SendResult
+
+	for i := 0; i < sampleSize; i++ {
+
+		if i == targetIdx {
+			r, _, _ := utils.BN254_GenG1KeyPair()
+			sent, _ = sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-2bytes"})
+
+			Rs = append(Rs, sent.R)
+			viewTags = append(viewTags, sent.ViewTag)
+			continue
+		}
+
+		_, R, _ := utils.BN254_GenG1KeyPair()
+		Rs = append(Rs, R)
+		viewTags = append(viewTags, "0000")
+	}
+
+	s, err := recipient.NewScanner(keys, "v0-2bytes")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	var stats recipient.Stats
+	matches, err := s.Scan(Rs, viewTags, &stats)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	found := false
+	for _, m := range matches {
+		if m.Index == targetIdx {
+			found = m.Address == sent.StealthAddress && m.R == Rs[targetIdx] && m.SpendingKey != nil
+		}
+	}
+
+	if !found {
+		t.Fatalf(`ERR: recipient did not find the announcement at index %d !!!`, targetIdx)
+	}
+
+	if stats.NAnnouncements != sampleSize || stats.NFullRuns != len(matches) {
+		t.Fatalf(`ERR: unexpected stats: %+v`, stats)
+	}
+
+	if _, err := s.Scan(Rs, viewTags[1:], nil); err == nil {
+		t.Fatalf(`ERR: mismatched number of view tags accepted !!!`)
+	}
+}
+
+func Test_Scanner_Parallel(t *testing.T) {
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+
+	sampleSize := 2_000
+	targets := map[int]bool{3: true, 255: true, 256: true, 1_000: true, 1_999: true}
+
+	var Rs []BN254.G1Affine
+	var viewTags []string
+
+	for i := 0; i < sampleSize; i++ {
+
+		if targets[i] {
+			r, _, _ := utils.BN254_GenG1KeyPair()
+			sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-1byte"})
+
+			Rs = append(Rs, sent.R)
+			viewTags = append(viewTags, sent.ViewTag)
+			continue
+		}
+
+		_, R, _ := utils.BN254_GenG1KeyPair()
+		Rs = append(Rs, R)
+		viewTags = append(viewTags, "00")
+	}
+
+	s, _ := recipient.NewScanner(keys, "v0-1byte")
+
+	var expected []recipient.Match
+
+	for _, parallelism := range []int{1, 3, 8, 0} {
+
+		s.Parallelism = parallelism
+
+		var stats recipient.Stats
+		matches, err := s.Scan(Rs, viewTags, &stats)
+		if err != nil {
+			t.Fatalf(`ERR: parallelism %d: %v`, parallelism, err)
+		}
+
+		if stats.NAnnouncements != sampleSize || stats.NFullRuns != len(matches) {
+			t.Fatalf(`ERR: parallelism %d: unexpected stats: %+v`, parallelism, stats)
+		}
+
+		if expected == nil {
+			expected = matches
+
+			nFound := 0
+			for _, m := range matches {
+				if targets[m.Index] {
+					nFound++
+				}
+			}
+			if nFound != len(targets) {
+				t.Fatalf(`ERR: found %d of %d announcements !!!`, nFound, len(targets))
+			}
+			continue
+		}
+
+		if len(matches) != len(expected) {
+			t.Fatalf(`ERR: parallelism %d: got %d matches, expected %d`, parallelism, len(matches), len(expected))
+		}
+		for j := range matches {
+			if matches[j].Index != expected[j].Index || matches[j].Address != expected[j].Address {
+				t.Fatalf(`ERR: parallelism %d: match %d differs (index %d vs. %d)`, parallelism, j, matches[j].Index, expected[j].Index)
+			}
+		}
+	}
+}
+
+func Test_Checker(t *testing.T) {
+
+	for _, version := range versions.List() {
+
+		p, _ := versions.Get(version)
+		keys, _ := p.GenerateMetaAddress()
+
+		c, err := p.NewChecker(&keys)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		for i := 0; i < 3; i++ {
+
+			_, R, _ := utils.BN254_GenG1KeyPair()
+
+			expected, _ := p.CheckAnnouncement(&keys, &R, nil)
+
+			vR := c.SharedPoint(&R)
+			if expectedVR := utils.BN254_MulG1PointandElement(&R, &keys.PK_v); !vR.Equal(&expectedVR) {
+				t.Fatalf(`ERR: %s: wrong shared point`, version)
+			}
+
+			//note: repeated, the precomputed values must not change between the calls
+			for _, in := range []*BN254.G1Affine{nil, &vR, nil} {
+
+				stealth, err := c.CheckAnnouncement(&R, in)
+				if err != nil {
+					t.Fatalf(`ERR: %s: %v`, version, err)
+				}
+
+				if !stealth.P_GT.Equal(&expected.P_GT) || stealth.Address != expected.Address || !stealth.SharedPoint.Equal(&vR) {
+					t.Fatalf(`ERR: %s: precomputed check differs from 'CheckAnnouncement'`, version)
+				}
+			}
+		}
+	}
+}
+
+func Test_DeriveStealthPrivateKey(t *testing.T) {
+
+	_, _, G1, G2 := BN254.Generators()
+
+	for _, version := range []string{"v0", "v1", "v2"} {
+
+		p, _ := versions.Get(version)
+		keys, _ := p.GenerateMetaAddress()
+
+		r, _, _ := utils.BN254_GenG1KeyPair()
+		sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-1byte"})
+
+		s, _ := recipient.NewScanner(keys, "v0-1byte")
+		matches, err := s.Scan([]BN254.G1Affine{sent.R}, []string{sent.ViewTag}, nil)
+		if err != nil || len(matches) != 1 || matches[0].SpendingKey == nil {
+			t.Fatalf(`ERR: %s: stealth private key not derived (%v)`, version, err)
+		}
+
+		privKey := matches[0].SpendingKey
+
+		// The stealth public key computed from the private key (see: `Protocol.DeriveStealthPrivateKey`)
+		var P []byte
+		switch version {
+		case "v0":
+			base, _ := BN254.Pair([]BN254.G1Affine{sent.R}, []BN254.G2Affine{G2})
+			var P_GT BN254.GT
+			P = P_GT.Exp(base, privKey).Marshal()
+		case "v1":
+			base, _ := BN254.Pair([]BN254.G1Affine{G1}, []BN254.G2Affine{G2})
+			var P_GT BN254.GT
+			P = P_GT.Exp(base, privKey).Marshal()
+		case "v2":
+			var P_SECP256k1 SECP256K1.G1Affine
+			P_SECP256k1.ScalarMultiplicationBase(privKey)
+			P_asBytes := P_SECP256k1.RawBytes()
+			P = P_asBytes[:]
+		}
+
+		if !bytes.Equal(P, sent.StealthPubKey) || !bytes.Equal(P, matches[0].StealthPubKey) {
+			t.Fatalf(`ERR: %s: stealth private key does not match the stealth public key !!!`, version)
+		}
+	}
+}
+
+var errDerivationFailed = errors.New("derivation failed")
+
+// failingKeyProtocol is v2 whose stealth private key derivation fails
+type failingKeyProtocol struct{ ecpdksap_v2.Protocol }
+
+func (failingKeyProtocol) DeriveStealthPrivateKey(*protocol.Keys, *protocol.Stealth) (*big.Int, error) {
+	return nil, errDerivationFailed
+}
+
+func Test_DeriveStealthPrivateKey_Error(t *testing.T) {
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+
+	r, _, _ := utils.BN254_GenG1KeyPair()
+	sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-1byte"})
+
+	//note: the error is returned instead of a match without the stealth private key
+	s, _ := recipient.NewScanner(keys, "v0-1byte")
+	s.Protocol = failingKeyProtocol{}
+
+	if matches, err := s.Scan([]BN254.G1Affine{sent.R}, []string{sent.ViewTag}, nil); !errors.Is(err, errDerivationFailed) || len(matches) != 0 {
+		t.Fatalf(`ERR: derivation error not returned: %+v (%v)`, matches, err)
+	}
+
+	// The watch-only scanner does not derive the stealth private key
+
+	s, _ = recipient.NewWatchOnlyScanner(keys, "v0-1byte")
+	s.Protocol = failingKeyProtocol{}
+
+	matches, err := s.Scan([]BN254.G1Affine{sent.R}, []string{sent.ViewTag}, nil)
+	if err != nil || len(matches) != 1 || matches[0].SpendingKey != nil || matches[0].Address != sent.StealthAddress {
+		t.Fatalf(`ERR: unexpected watch-only matches: %+v (%v)`, matches, err)
+	}
+}
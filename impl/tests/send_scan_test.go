@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+	"ecpdksap-go/view_tags"
+)
+
+// Regression suite: every (version, view tag) combination goes through `Send`, the announcement encoding and `Scan`
+func Test_SendThenScan(t *testing.T) {
+
+	sampleSize := 20
+	targetIdx := 13
+
+	for _, version := range []string{"v0", "v1", "v2"} {
+
+		p, _ := versions.Get(version)
+		keys, _ := p.GenerateMetaAddress()
+
+		for _, viewTagVersion := range view_tags.List() {
+
+			r, _, _ := utils.BN254_GenG1KeyPair()
+			sent, err := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: viewTagVersion})
+			if err != nil {
+				t.Fatalf(`ERR: %s, %s: %v`, version, viewTagVersion, err)
+			}
+
+			ephemeralPubKey, metadata, err := announcement.Encode(&sent.R, sent.ViewTag, viewTagVersion)
+			if err != nil {
+				t.Fatalf(`ERR: %s, %s: %v`, version, viewTagVersion, err)
+			}
+
+			var Rs []BN254.G1Affine
+			var viewTags []string
+			for i := 0; i < sampleSize; i++ {
+
+				if i == targetIdx {
+					R, viewTag, err := announcement.Decode(ephemeralPubKey, metadata, viewTagVersion)
+					if err != nil {
+						t.Fatalf(`ERR: %s, %s: %v`, version, viewTagVersion, err)
+					}
+
+					Rs = append(Rs, R)
+					viewTags = append(viewTags, viewTag)
+					continue
+				}
+
+				//note: foreign announcement (sent to someone else)
+				other, _ := p.GenerateMetaAddress()
+				r, _, _ := utils.BN254_GenG1KeyPair()
+				foreign, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: other.Meta, ViewTagVersion: viewTagVersion})
+
+				Rs = append(Rs, foreign.R)
+				viewTags = append(viewTags, foreign.ViewTag)
+			}
+
+			s, _ := recipient.NewScanner(keys, viewTagVersion)
+
+			matches, err := s.Scan(Rs, viewTags, nil)
+			if err != nil {
+				t.Fatalf(`ERR: %s, %s: %v`, version, viewTagVersion, err)
+			}
+
+			found := false
+			for _, m := range matches {
+				if m.Index == targetIdx {
+					found = bytes.Equal(m.StealthPubKey, sent.StealthPubKey) && m.Address == sent.StealthAddress
+				} else if bytes.Equal(m.StealthPubKey, sent.StealthPubKey) {
+					t.Fatalf(`ERR: %s, %s: foreign announcement %d matched the stealth public key !!!`, version, viewTagVersion, m.Index)
+				}
+			}
+
+			if !found {
+				t.Fatalf(`ERR: %s, %s: sent announcement not found by the recipient !!!`, version, viewTagVersion)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+
+	"ecpdksap-go/gen_example"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+func Test_Send(t *testing.T) {
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+
+	r, _, _ := utils.BN254_GenG1KeyPair()
+
+	res, err := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-1byte"})
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	stealth, _ := p.CheckAnnouncement(&keys, &res.R, nil)
+
+	if res.StealthAddress == "" || res.StealthAddress != stealth.Address {
+		t.Fatalf(`ERR: sender and recipient calculated different address !!!`)
+	}
+
+	if _, err := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v9-1byte"}); err == nil {
+		t.Fatalf(`ERR: unknown view tag version accepted !!!`)
+	}
+}
+
+func Test_SendFromJSON_MalformedInput(t *testing.T) {
+
+	V := "1.2"
+	K := "1.2"
+	r := "1dd06ca07978ccae708ae87f9da237570a928e1597addb675a3d65997da5fbf9"
+
+	inputs := map[string]string{
+		"invalid JSON":        `{"r": `,
+		"unknown version":     `{"r": "` + r + `", "K": "` + K + `", "V": "` + V + `", "Version": "v9", "ViewTagVersion": "none"}`,
+		"non-hex r":           `{"r": "xyz", "K": "` + K + `", "V": "` + V + `", "Version": "v2", "ViewTagVersion": "none"}`,
+		"non-canonical r":     `{"r": "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "K": "` + K + `", "V": "` + V + `", "Version": "v2", "ViewTagVersion": "none"}`,
+		"K without separator": `{"r": "` + r + `", "K": "12", "V": "` + V + `", "Version": "v2", "ViewTagVersion": "none"}`,
+		"non-decimal V":       `{"r": "` + r + `", "K": "` + K + `", "V": "a.b", "Version": "v2", "ViewTagVersion": "none"}`,
+	}
+
+	for name, input := range inputs {
+		if _, err := sender.SendFromJSON(input); err == nil {
+			t.Fatalf(`ERR: %s: malformed input accepted !!!`, name)
+		}
+	}
+}
+
+func Test_SendFromJSON_V2_MatchesRecipient(t *testing.T) {
+
+	sendParams, recipientParams := gen_example.GenerateExample("v2", "v0-1byte", "1")
+
+	sendJSON, _ := json.Marshal(sendParams)
+	sent, err := sender.SendFromJSON(string(sendJSON))
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	if !strings.HasPrefix(sent.StealthAddress, "0x") || len(sent.StealthAddress) != 42 {
+		t.Fatalf(`ERR: invalid stealth address %q`, sent.StealthAddress)
+	}
+
+	//note: the recipient scans what the sender announced
+	recipientParams.Rs = []string{sent.R}
+	recipientParams.ViewTags = []string{sent.Metadata}
+
+	receiveJSON, _ := json.Marshal(recipientParams)
+	received, _, err := recipient.ScanFromJSON(string(receiveJSON))
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	if len(received.Matches) != 1 {
+		t.Fatalf(`ERR: announcement not found by the recipient !!!`)
+	}
+
+	m := received.Matches[0]
+	if m.R != sent.R || m.Address != sent.StealthAddress || m.StealthPubKey != sent.StealthPubKey {
+		t.Fatalf(`ERR: sender and recipient calculated different stealth info: %+v vs. %+v`, sent, m)
+	}
+}
+
+func Test_G2PointFromString(t *testing.T) {
+
+	_, K, _ := utils.BN254_GenG2KeyPair()
+
+	decimal := K.X.A0.String() + "." + K.X.A1.String() + "." + K.Y.A0.String() + "." + K.Y.A1.String()
+
+	for _, in := range []string{utils.BN254_G2PointToString(&K), "0x" + utils.BN254_G2PointToString(&K), decimal} {
+		parsed, err := utils.BN254_G2PointFromString(in)
+		if err != nil || !parsed.Equal(&K) {
+			t.Fatalf(`ERR: %s: G2 point not parsed: %v`, in, err)
+		}
+	}
+
+	//note: on the curve, but (without clearing the cofactor) not in the G2 subgroup
+	var u BN254.E2
+	u.SetString("5", "7")
+	notInSubGroup := BN254.MapToCurve2(&u)
+	if !notInSubGroup.IsOnCurve() || notInSubGroup.IsInSubGroup() {
+		t.Fatalf(`ERR: invalid test point`)
+	}
+
+	var infinity BN254.G2Affine
+
+	invalid := map[string]string{
+		"not on curve":       "1.2.3.4",
+		"not in subgroup":    notInSubGroup.X.A0.String() + "." + notInSubGroup.X.A1.String() + "." + notInSubGroup.Y.A0.String() + "." + notInSubGroup.Y.A1.String(),
+		"infinity":           utils.BN254_G2PointToString(&infinity),
+		"X only (legacy)":    K.X.A0.String() + "." + K.X.A1.String(),
+		"truncated":          utils.BN254_G2PointToString(&K)[:126],
+		"non-decimal coord.": "a.2.3.4",
+	}
+
+	for name, in := range invalid {
+		if _, err := utils.BN254_G2PointFromString(in); err == nil {
+			t.Fatalf(`ERR: %s: invalid G2 point accepted !!!`, name)
+		}
+	}
+}
+
+func Test_SendFromJSON_G2(t *testing.T) {
+
+	for _, version := range []string{"v0", "v1"} {
+
+		sendParams, recipientParams := gen_example.GenerateExample(version, "v0-1byte", "1")
+
+		sendJSON, _ := json.Marshal(sendParams)
+		sendParams.MetaAddress = ""
+
+		//note: K, V and Version only (without the meta address)
+		sendJSON_asXY, _ := json.Marshal(sendParams)
+
+		for _, input := range [][]byte{sendJSON, sendJSON_asXY} {
+
+			sent, err := sender.SendFromJSON(string(input))
+			if err != nil {
+				t.Fatalf(`ERR: %s: %v`, version, err)
+			}
+
+			recipientParams.Rs = []string{sent.R}
+			recipientParams.ViewTags = []string{sent.ViewTag}
+
+			receiveJSON, _ := json.Marshal(recipientParams)
+			received, _, err := recipient.ScanFromJSON(string(receiveJSON))
+			if err != nil || len(received.Matches) != 1 || received.Matches[0].StealthPubKey != sent.StealthPubKey {
+				t.Fatalf(`ERR: %s: sender and recipient calculated different stealth public key (%v)`, version, err)
+			}
+		}
+	}
+}
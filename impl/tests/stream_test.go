@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/keygen"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+func Test_ScanStream(t *testing.T) {
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+
+	//note: spans several batches for a single worker
+	sampleSize := 2_500
+	targets := map[int]bool{0: true, 1_023: true, 1_024: true, 2_499: true}
+
+	var ndjson, binary bytes.Buffer
+
+	for i := 0; i < sampleSize; i++ {
+
+		var ann recipient.AnnouncementData
+		var record []byte
+
+		if targets[i] {
+			r, _, _ := utils.BN254_GenG1KeyPair()
+			sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-2bytes"})
+
+			ann = recipient.AnnouncementData{R: hex.EncodeToString(announcement.EncodeR(&sent.R)), ViewTag: sent.ViewTag}
+			record, _ = recipient.EncodeBinaryRecord(&sent.R, sent.ViewTag, "v0-2bytes")
+		} else {
+			_, R, _ := utils.BN254_GenG1KeyPair()
+
+			ann = recipient.AnnouncementData{R: hex.EncodeToString(announcement.EncodeR(&R)), ViewTag: "0000"}
+			record, _ = recipient.EncodeBinaryRecord(&R, "0000", "v0-2bytes")
+		}
+
+		line, _ := json.Marshal(ann)
+		ndjson.Write(append(line, '\n'))
+		binary.Write(record)
+	}
+
+	s, _ := recipient.NewScanner(keys, "v0-2bytes")
+	s.Parallelism = 1
+
+	binaryReader, err := recipient.NewBinaryReader(bytes.NewReader(binary.Bytes()), "v0-2bytes")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	for name, reader := range map[string]recipient.AnnouncementReader{
+		"ndjson": recipient.NewNDJSONReader(bytes.NewReader(ndjson.Bytes())),
+		"binary": binaryReader,
+	} {
+		var stats recipient.Stats
+		var indices []int
+
+		err := s.ScanStream(reader, func(m recipient.Match) error {
+			if m.SpendingKey == nil {
+				t.Fatalf(`ERR: %s: no spending key for match %d`, name, m.Index)
+			}
+			indices = append(indices, m.Index)
+			return nil
+		}, &stats)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, name, err)
+		}
+
+		nFound := 0
+		for j, idx := range indices {
+			if j > 0 && indices[j-1] >= idx {
+				t.Fatalf(`ERR: %s: matches out of order: %v`, name, indices)
+			}
+			if targets[idx] {
+				nFound++
+			}
+		}
+
+		if nFound != len(targets) || stats.NAnnouncements != sampleSize {
+			t.Fatalf(`ERR: %s: found %d of %d announcements (stats: %+v)`, name, nFound, len(targets), stats)
+		}
+	}
+
+	// Truncated binary stream
+
+	truncatedReader, _ := recipient.NewBinaryReader(bytes.NewReader(binary.Bytes()[:binary.Len()-1]), "v0-2bytes")
+	if err := s.ScanStream(truncatedReader, func(recipient.Match) error { return nil }, nil); err == nil {
+		t.Fatalf(`ERR: truncated binary stream accepted`)
+	}
+}
+
+func Test_ScanStreamFromJSON(t *testing.T) {
+
+	keys, keysData, _ := keygen.GenerateKeys("v0")
+
+	r, _, _ := utils.BN254_GenG1KeyPair()
+	sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "none"})
+	_, other, _ := utils.BN254_GenG1KeyPair()
+
+	stdin := `{"R": "` + hex.EncodeToString(announcement.EncodeR(&other)) + `"}` + "\n\n" +
+		`{"R": "0x` + hex.EncodeToString(announcement.EncodeR(&sent.R)) + `"}` + "\n"
+
+	input := `{"k": "` + keysData.PK_k + `", "v": "` + keysData.PK_v + `", "Version": "v0", "ViewTagVersion": "none", "Announcements": "-"}`
+
+	var out bytes.Buffer
+	if _, err := recipient.ScanStreamFromJSON(input, strings.NewReader(stdin), &out); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	//note: without a view tag every announcement is emitted
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf(`ERR: expected 2 matches, got: %s`, out.String())
+	}
+
+	var match recipient.MatchOutputData
+	if err := json.Unmarshal([]byte(lines[1]), &match); err != nil || match.Index != 1 || match.StealthPubKey != hex.EncodeToString(sent.StealthPubKey) {
+		t.Fatalf(`ERR: unexpected output: %s (%v)`, lines[1], err)
+	}
+
+	//note: an R not on the curve is skipped and counted, a malformed R or line stops the scan
+	if stats, err := recipient.ScanStreamFromJSON(input, strings.NewReader(`{"R": "`+strings.Repeat("8f", 32)+`"}`), &out); err != nil || stats.NInvalid != 1 {
+		t.Fatalf(`ERR: invalid R not skipped: %+v (%v)`, stats, err)
+	}
+
+	if _, err := recipient.ScanStreamFromJSON(input, strings.NewReader(`{"R": "zz"}`), &out); err == nil {
+		t.Fatalf(`ERR: malformed R accepted`)
+	}
+
+	if _, err := recipient.ScanStreamFromJSON(input, strings.NewReader(`{"R": `), &out); err == nil {
+		t.Fatalf(`ERR: invalid announcement accepted`)
+	}
+}
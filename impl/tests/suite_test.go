@@ -8,6 +8,7 @@ import (
 	ecpdksap_v2 "ecpdksap-go/versions/v2"
 
 	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
 )
 
 func Test_V0(t *testing.T) {
@@ -70,3 +71,47 @@ func Test_V2(t *testing.T) {
 		t.Fatalf(`ERR: sender and recipient calculated different 'b' !!!`)
 	}
 }
+
+func Test_Protocols(t *testing.T) {
+
+	for _, version := range versions.List() {
+
+		p, err := versions.Get(version)
+		if err != nil {
+			t.Fatalf(`ERR: %v`, err)
+		}
+
+		keys, err := p.GenerateMetaAddress()
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		r, _, _ := utils.BN254_GenG1KeyPair()
+
+		S_Sender, err := p.DeriveStealth(&r, &keys.Meta)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		S_Recipient, err := p.CheckAnnouncement(&keys, &S_Sender.R, nil)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		if S_Sender.SharedPoint != S_Recipient.SharedPoint {
+			t.Fatalf(`ERR: %s: sender and recipient calculated different shared point !!!`, version)
+		}
+
+		if S_Sender.P_GT != S_Recipient.P_GT {
+			t.Fatalf(`ERR: %s: sender and recipient calculated different public key !!!`, version)
+		}
+
+		if S_Sender.Address != S_Recipient.Address {
+			t.Fatalf(`ERR: %s: sender and recipient calculated different address !!!`, version)
+		}
+	}
+
+	if _, err := versions.Get("v3"); err == nil {
+		t.Fatalf(`ERR: unknown protocol version accepted !!!`)
+	}
+}
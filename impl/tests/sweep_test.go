@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+	"golang.org/x/crypto/sha3"
+
+	"ecpdksap-go/chain"
+	"ecpdksap-go/events"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sweep"
+	"ecpdksap-go/versions"
+)
+
+func Test_Sweep_Address(t *testing.T) {
+
+	for privKey, expected := range map[int64]string{
+		1: "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf",
+		2: "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf",
+	} {
+		address, err := sweep.Address(big.NewInt(privKey))
+		if err != nil || address != expected {
+			t.Fatalf(`ERR: private key %d: unexpected address: %s, %v`, privKey, address, err)
+		}
+	}
+
+	for _, privKey := range []*big.Int{big.NewInt(0), SECP256K1_fr.Modulus()} {
+		if _, err := sweep.Address(privKey); err == nil {
+			t.Fatalf(`ERR: private key %s accepted !!!`, privKey)
+		}
+	}
+}
+
+func Test_Sweep_Sign(t *testing.T) {
+
+	privKey, _ := new(big.Int).SetString("4646464646464646464646464646464646464646464646464646464646464646", 16)
+
+	tx := sweep.Transaction{
+		ChainId:              big.NewInt(1),
+		Nonce:                9,
+		MaxPriorityFeePerGas: big.NewInt(1_000_000_000),
+		MaxFeePerGas:         big.NewInt(30_000_000_000),
+		Gas:                  50_000,
+		To:                   "0x3535353535353535353535353535353535353535",
+		Value:                big.NewInt(1_000_000_000_000_000_000),
+		Data:                 bytes.Repeat([]byte{0xab}, 60),
+	}
+
+	hash, err := tx.SigningHash()
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	halfOrder := new(big.Int).Rsh(SECP256K1_fr.Modulus(), 1)
+
+	//note: the signatures are randomized, every one must recover the sender and be low-s
+	for i := 0; i < 16; i++ {
+
+		signed, err := tx.Sign(privKey)
+		if err != nil {
+			t.Fatalf(`ERR: %v`, err)
+		}
+
+		if signed.From != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" || signed.Signature.S.Cmp(halfOrder) > 0 {
+			t.Fatalf(`ERR: unexpected signed transaction: %+v`, signed)
+		}
+
+		from, err := sweep.RecoverAddress(hash, signed.Signature)
+		if err != nil || from != signed.From {
+			t.Fatalf(`ERR: recovered %s instead of %s: %v`, from, signed.From, err)
+		}
+
+		// Raw transaction: 0x02 || rlp([chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gas, to, value, data, accessList, yParity, r, s])
+
+		if signed.RawTx[0] != sweep.DynamicFeeTxType {
+			t.Fatalf(`ERR: unexpected transaction type: %d`, signed.RawTx[0])
+		}
+
+		fields := rlpDecodeList(t, signed.RawTx[1:])
+
+		expected := [][]byte{
+			{0x01}, {0x09}, big.NewInt(1_000_000_000).Bytes(), big.NewInt(30_000_000_000).Bytes(), big.NewInt(50_000).Bytes(),
+			bytes.Repeat([]byte{0x35}, 20), big.NewInt(1_000_000_000_000_000_000).Bytes(), tx.Data, {},
+			new(big.Int).SetUint64(uint64(signed.Signature.YParity)).Bytes(), signed.Signature.R.Bytes(), signed.Signature.S.Bytes(),
+		}
+
+		if len(fields) != len(expected) {
+			t.Fatalf(`ERR: raw transaction has %d fields`, len(fields))
+		}
+		for j := range expected {
+			if !bytes.Equal(fields[j], expected[j]) {
+				t.Fatalf(`ERR: unexpected raw transaction field %d: %x`, j, fields[j])
+			}
+		}
+	}
+
+	// Invalid inputs
+
+	if _, err := tx.Sign(big.NewInt(0)); err == nil {
+		t.Fatalf(`ERR: zero private key accepted !!!`)
+	}
+
+	invalidTo := tx
+	invalidTo.To = "0x35"
+	if _, err := invalidTo.Sign(privKey); err == nil {
+		t.Fatalf(`ERR: invalid recipient accepted !!!`)
+	}
+}
+
+// Known answers: the EIP-155 example (https://eips.ethereum.org/EIPS/eip-155) for the secp256k1 signature,
+// its transaction as EIP-1559 for the encoding
+func Test_Sweep_Vectors(t *testing.T) {
+
+	// EIP-155: private key 0x4646..46, signing hash of the chain id 1 legacy transaction and its signature (v = 37)
+
+	hash, _ := hex.DecodeString("daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53")
+	r, _ := new(big.Int).SetString("18515461264373351373200002665853028612451056578545711640558177340181847433846", 10)
+	s, _ := new(big.Int).SetString("46948507304638947509940763649030358759909902576025900602547168820602576006531", 10)
+
+	if from, err := sweep.RecoverAddress(hash, sweep.Signature{YParity: 0, R: r, S: s}); err != nil || from != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" {
+		t.Fatalf(`ERR: EIP-155 vector: recovered %s: %v`, from, err)
+	}
+
+	// EIP-1559: the same transaction (max fees: the gas price) signed with the same key
+
+	tx := sweep.Transaction{
+		ChainId:              big.NewInt(1),
+		Nonce:                9,
+		MaxPriorityFeePerGas: big.NewInt(20_000_000_000),
+		MaxFeePerGas:         big.NewInt(20_000_000_000),
+		Gas:                  21_000,
+		To:                   "0x3535353535353535353535353535353535353535",
+		Value:                big.NewInt(1_000_000_000_000_000_000),
+	}
+
+	unsigned := "02" + // transaction type
+		"f1" + // list of 49 bytes
+		"01" + // chain id
+		"09" + // nonce
+		"8504a817c800" + // max priority fee per gas
+		"8504a817c800" + // max fee per gas
+		"825208" + // gas
+		"943535353535353535353535353535353535353535" + // to
+		"880de0b6b3a7640000" + // value
+		"80" + // data
+		"c0" // access list
+
+	unsignedBytes, _ := hex.DecodeString(unsigned)
+	keccak := sha3.NewLegacyKeccak256()
+	keccak.Write(unsignedBytes)
+
+	hash, err := tx.SigningHash()
+	if err != nil || !bytes.Equal(hash, keccak.Sum(nil)) || hex.EncodeToString(hash) != "2f993a4a9c19ad6688827dd50a74c323c031f943a0d2533fe212cd8157a03c37" {
+		t.Fatalf(`ERR: unexpected signing hash: %x (%v)`, hash, err)
+	}
+
+	sig := sweep.Signature{YParity: 1}
+	sig.R, _ = new(big.Int).SetString("7702f3bb20cfbf5ed99b224f14f11fbbff26353f7f13e7a12703c525ea31a311", 16)
+	sig.S, _ = new(big.Int).SetString("0f2020882bf67bd8e405357f4576dfe57214530320150fc58b0ce55b0d16e7c6", 16)
+
+	if from, err := sweep.RecoverAddress(hash, sig); err != nil || from != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" {
+		t.Fatalf(`ERR: EIP-1559 vector: recovered %s: %v`, from, err)
+	}
+
+	expected := "02" +
+		"f874" + // list of 116 bytes
+		unsigned[4:] +
+		"01" + // y parity
+		"a07702f3bb20cfbf5ed99b224f14f11fbbff26353f7f13e7a12703c525ea31a311" + // r
+		"a00f2020882bf67bd8e405357f4576dfe57214530320150fc58b0ce55b0d16e7c6" // s
+
+	rawTx, err := tx.RawTransaction(sig)
+	if err != nil || hex.EncodeToString(rawTx) != expected {
+		t.Fatalf(`ERR: unexpected raw transaction: %x (%v)`, rawTx, err)
+	}
+
+	//note: the signature is randomized, the signed transaction is the encoding of its own signature
+	privKey, _ := new(big.Int).SetString("4646464646464646464646464646464646464646464646464646464646464646", 16)
+
+	signed, err := tx.Sign(privKey)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	if rawTx, _ = tx.RawTransaction(signed.Signature); !bytes.Equal(signed.RawTx, rawTx) {
+		t.Fatalf(`ERR: unexpected signed transaction: %x`, signed.RawTx)
+	}
+
+	if _, err := tx.RawTransaction(sweep.Signature{}); err == nil {
+		t.Fatalf(`ERR: missing signature accepted !!!`)
+	}
+}
+
+// Sweep of the addresses found on the simulated chain (see: `Test_Chain`)
+func Test_Sweep(t *testing.T) {
+
+	jsonBytes, err := os.ReadFile("fixtures/announcement_logs.json")
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	var logs []events.Log
+	if err = json.Unmarshal(jsonBytes, &logs); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	k, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000007")
+	v, _ := hex.DecodeString("1dd06ca07978ccae708ae87f9da237570a928e1597addb675a3d65997da5fbf9")
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.KeysFromPrivate(k, v)
+
+	s, _ := recipient.NewScanner(keys, "v0-1byte")
+
+	matches, err := events.ScanLogs(s, logs, nil)
+	if err != nil || len(matches) != 2 {
+		t.Fatalf(`ERR: unexpected matches: %+v, %v`, matches, err)
+	}
+
+	node := chain.NewFakeNode()
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := chain.NewRPCClient(server.URL)
+
+	// First address funded (3 transactions sent already), the second one cannot pay the fee
+
+	oneEth := big.NewInt(1_000_000_000_000_000_000)
+	node.SetBalance(matches[0].Address, oneEth)
+	node.SetNonce(matches[0].Address, 3)
+	node.SetBalance(matches[1].Address, big.NewInt(21_000*20_000_000_000))
+
+	params := sweep.Params{
+		ChainId:              big.NewInt(11155111),
+		Destination:          "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf",
+		MaxFeePerGas:         big.NewInt(20_000_000_000),
+		MaxPriorityFeePerGas: big.NewInt(1_000_000_000),
+	}
+
+	txs, skipped, err := sweep.Sweep(ctx, client, matches, &params)
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	expectedValue := new(big.Int).Sub(oneEth, big.NewInt(21_000*20_000_000_000))
+
+	if len(txs) != 1 || len(skipped) != 1 || skipped[0] != matches[1].Address ||
+		txs[0].From != matches[0].Address || txs[0].Tx.Nonce != 3 || txs[0].Tx.Gas != sweep.TransferGas || txs[0].Tx.Value.Cmp(expectedValue) != 0 {
+		t.Fatalf(`ERR: unexpected sweep: %+v, skipped: %v`, txs, skipped)
+	}
+
+	hash, _ := txs[0].Tx.SigningHash()
+	if from, err := sweep.RecoverAddress(hash, txs[0].Signature); err != nil || from != matches[0].Address {
+		t.Fatalf(`ERR: recovered %s instead of %s: %v`, from, matches[0].Address, err)
+	}
+
+	if err = sweep.Broadcast(ctx, client, txs); err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+	if sent := node.SentTransactions(); len(sent) != 1 || !bytes.Equal(sent[0], txs[0].RawTx) {
+		t.Fatalf(`ERR: unexpected sent transactions: %x`, sent)
+	}
+
+	// Matches without a usable stealth private key
+
+	watchOnly := matches[0]
+	watchOnly.SpendingKey = nil
+
+	otherKey := matches[0]
+	otherKey.SpendingKey = matches[1].SpendingKey
+
+	for name, m := range map[string]recipient.Match{"watch-only": watchOnly, "other key": otherKey, "v0/v1": {R: matches[0].R}} {
+		if _, _, err := sweep.Sweep(ctx, client, []recipient.Match{m}, &params); err == nil {
+			t.Fatalf(`ERR: %s: match swept !!!`, name)
+		}
+	}
+
+	// JSON: the `receive-scan` output matches, with the balances (offline) or from the node
+
+	for _, rpc := range []string{"", server.URL} {
+
+		input := map[string]any{
+			"ChainId":              11155111,
+			"Destination":          params.Destination,
+			"MaxFeePerGas":         "20000000000",
+			"MaxPriorityFeePerGas": "0x3b9aca00",
+			"RPC":                  rpc,
+			"Matches": []map[string]any{
+				{"Index": 0, "Address": matches[0].Address, "SpendingKey": "0x" + matches[0].SpendingKey.Text(16), "Balance": oneEth.String(), "Nonce": 3},
+				{"Index": 4, "Address": matches[1].Address, "SpendingKey": "0x" + matches[1].SpendingKey.Text(16), "Balance": "0"},
+			},
+		}
+		inputBytes, _ := json.Marshal(input)
+
+		output, err := sweep.SweepFromJSON(string(inputBytes))
+		if err != nil {
+			t.Fatalf(`ERR: %v`, err)
+		}
+
+		if len(output.Transactions) != 1 || len(output.Skipped) != 1 ||
+			output.Transactions[0].From != matches[0].Address || output.Transactions[0].Nonce != 3 || output.Transactions[0].Value != expectedValue.String() ||
+			!strings.HasPrefix(output.Transactions[0].RawTx, "0x02") {
+			t.Fatalf(`ERR: RPC %q: unexpected output: %+v`, rpc, output)
+		}
+	}
+}
+
+// rlpDecodeList returns the payloads of the list's items (the nested lists are not decoded)
+func rlpDecodeList(t *testing.T, in []byte) (items [][]byte) {
+
+	payload, rest := rlpDecodeItem(t, in)
+	if len(rest) != 0 {
+		t.Fatalf(`ERR: %d bytes after the RLP list`, len(rest))
+	}
+
+	for len(payload) != 0 {
+		var item []byte
+		item, payload = rlpDecodeItem(t, payload)
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func rlpDecodeItem(t *testing.T, in []byte) (payload []byte, rest []byte) {
+
+	if len(in) == 0 {
+		t.Fatalf(`ERR: empty RLP item`)
+	}
+
+	prefix := int(in[0])
+	start, length := 1, 0
+
+	switch {
+	case prefix < 0x80:
+		start, length = 0, 1
+	case prefix < 0xb8:
+		length = prefix - 0x80
+	case prefix < 0xc0:
+		start = 1 + prefix - 0xb7
+		length = int(new(big.Int).SetBytes(in[1:start]).Int64())
+	case prefix < 0xf8:
+		length = prefix - 0xc0
+	default:
+		start = 1 + prefix - 0xf7
+		length = int(new(big.Int).SetBytes(in[1:start]).Int64())
+	}
+
+	if start+length > len(in) {
+		t.Fatalf(`ERR: RLP item out of bounds`)
+	}
+
+	return in[start : start+length], in[start+length:]
+}
+
+func Test_SweepFromJSON_Malformed(t *testing.T) {
+
+	privKey := big.NewInt(7)
+	address, _ := sweep.Address(privKey)
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"ChainId":              11155111,
+			"Destination":          "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf",
+			"MaxFeePerGas":         "20000000000",
+			"MaxPriorityFeePerGas": "1000000000",
+			"Matches":              []map[string]any{{"Address": address, "SpendingKey": "0x7", "Balance": "1000000000000000000"}},
+		}
+	}
+
+	inputBytes, _ := json.Marshal(valid())
+	if output, err := sweep.SweepFromJSON(string(inputBytes)); err != nil || len(output.Transactions) != 1 {
+		t.Fatalf(`ERR: unexpected output: %+v, %v`, output, err)
+	}
+
+	cases := map[string]func(input map[string]any){
+		"missing chain id":       func(input map[string]any) { delete(input, "ChainId") },
+		"zero chain id":          func(input map[string]any) { input["ChainId"] = 0 },
+		"negative chain id":      func(input map[string]any) { input["ChainId"] = -1 },
+		"chain id string":        func(input map[string]any) { input["ChainId"] = "11155111" },
+		"missing max fee":        func(input map[string]any) { delete(input, "MaxFeePerGas") },
+		"non-numeric max fee":    func(input map[string]any) { input["MaxFeePerGas"] = "20 gwei" },
+		"tip above the max fee":  func(input map[string]any) { input["MaxPriorityFeePerGas"] = "30000000000" },
+		"short destination":      func(input map[string]any) { input["Destination"] = "0x2b5ad5c4" },
+		"broadcast without node": func(input map[string]any) { input["Broadcast"] = true },
+		"invalid spending key": func(input map[string]any) {
+			input["Matches"] = []map[string]any{{"Address": address, "SpendingKey": "0xzz", "Balance": "1"}}
+		},
+		"missing balance offline": func(input map[string]any) {
+			input["Matches"] = []map[string]any{{"Address": address, "SpendingKey": "0x7"}}
+		},
+	}
+
+	for name, malform := range cases {
+
+		input := valid()
+		malform(input)
+		inputBytes, _ := json.Marshal(input)
+
+		if _, err := sweep.SweepFromJSON(string(inputBytes)); err == nil {
+			t.Fatalf(`ERR: %s: malformed input accepted`, name)
+		}
+	}
+
+	if _, err := sweep.SweepFromJSON(`{"ChainId": 1,`); err == nil {
+		t.Fatalf(`ERR: truncated JSON accepted`)
+	}
+}
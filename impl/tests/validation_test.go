@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/validation"
+	"ecpdksap-go/versions"
+)
+
+func Test_Validation(t *testing.T) {
+
+	var notOnCurve_G1, infinity_G1 BN254.G1Affine
+	notOnCurve_G1.X.SetUint64(1)
+	notOnCurve_G1.Y.SetUint64(1)
+
+	var u BN254.E2
+	u.SetString("5", "7")
+	notInSubgroup_G2 := BN254.MapToCurve2(&u)
+
+	var notOnCurve_SECP256k1 SECP256K1.G1Affine
+	notOnCurve_SECP256k1.X.SetUint64(1)
+	notOnCurve_SECP256k1.Y.SetUint64(1)
+
+	_, R, _ := utils.BN254_GenG1KeyPair()
+	_, K, _ := utils.BN254_GenG2KeyPair()
+
+	for name, tc := range map[string]struct {
+		err      error
+		expected error
+	}{
+		"valid G1":             {validation.BN254_G1Point(&R), nil},
+		"valid G2":             {validation.BN254_G2Point(&K), nil},
+		"G1 not on curve":      {validation.BN254_G1Point(&notOnCurve_G1), validation.ErrNotOnCurve},
+		"G1 infinity":          {validation.BN254_G1Point(&infinity_G1), validation.ErrInfinity},
+		"G2 not in subgroup":   {validation.BN254_G2Point(&notInSubgroup_G2), validation.ErrNotInSubgroup},
+		"SECP256k1 off curve":  {validation.SECP256k1_G1Point(&notOnCurve_SECP256k1), validation.ErrNotOnCurve},
+		"G1 invalid encoding":  {func() error { _, err := announcement.DecodeR(bytes.Repeat([]byte{0x8f}, 32)); return err }(), validation.ErrInvalidEncoding},
+		"G1 infinity encoding": {func() error { _, err := announcement.DecodeR(announcement.EncodeR(&infinity_G1)); return err }(), validation.ErrInfinity},
+	} {
+		if !errors.Is(tc.err, tc.expected) || (tc.expected != nil) != validation.IsInvalidPoint(tc.err) {
+			t.Fatalf(`ERR: %s: expected %v, got %v`, name, tc.expected, tc.err)
+		}
+	}
+
+	// Sender rejects the invalid recipient's keys
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+	keys.Meta.V = notOnCurve_G1
+
+	r, _, _ := utils.BN254_GenG1KeyPair()
+	if _, err := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "none"}); !errors.Is(err, validation.ErrNotOnCurve) {
+		t.Fatalf(`ERR: invalid V accepted: %v`, err)
+	}
+}
+
+func Test_Scan_InvalidPoints(t *testing.T) {
+
+	p, _ := versions.Get("v0")
+	keys, _ := p.GenerateMetaAddress()
+
+	r, _, _ := utils.BN254_GenG1KeyPair()
+	sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "none"})
+
+	var notOnCurve, infinity BN254.G1Affine
+	notOnCurve.X.SetUint64(1)
+	notOnCurve.Y.SetUint64(1)
+
+	s, _ := recipient.NewScanner(keys, "none")
+
+	var stats recipient.Stats
+	matches, err := s.Scan([]BN254.G1Affine{notOnCurve, infinity, sent.R}, nil, &stats)
+	if err != nil || len(matches) != 1 || matches[0].Index != 2 || stats.NInvalid != 2 {
+		t.Fatalf(`ERR: invalid points not skipped: %+v, %+v (%v)`, matches, stats, err)
+	}
+
+	// JSON input and NDJSON stream, with a truncated R too
+
+	sent_R := hex.EncodeToString(announcement.EncodeR(&sent.R))
+
+	Rs := []string{"1.1", hex.EncodeToString(bytes.Repeat([]byte{0x8f}, 32)), hex.EncodeToString(announcement.EncodeR(&infinity)), sent_R[:62], sent_R}
+
+	ndjson := func(Rs []string) string {
+		var out strings.Builder
+		for i, R := range Rs {
+			line, _ := json.Marshal(recipient.AnnouncementData{R: R, BlockNumber: uint64(i + 1)})
+			out.Write(append(line, '\n'))
+		}
+		return out.String()
+	}
+
+	stats = recipient.Stats{}
+	matches = nil
+	err = s.ScanStream(recipient.NewNDJSONReader(strings.NewReader(ndjson(Rs))), func(m recipient.Match) error {
+		matches = append(matches, m)
+		return nil
+	}, &stats)
+	if err != nil || len(matches) != 1 || matches[0].Index != 4 || matches[0].BlockNumber != 5 || stats.NInvalid != 4 {
+		t.Fatalf(`ERR: invalid points not skipped in the stream: %+v, %+v (%v)`, matches, stats, err)
+	}
+
+	recipientInput := func(Rs []string) string {
+		return `{"k": "` + hex.EncodeToString(keys.PK_k_BN254.Marshal()) + `", "v": "` + hex.EncodeToString(keys.PK_v.Marshal()) + `", "Version": "v0", "ViewTagVersion": "none", "Rs": ["` + strings.Join(Rs, `", "`) + `"]}`
+	}
+
+	_, Rs_parsed, _, err := recipient.ParseRecipientInput(recipientInput(Rs))
+	if err != nil || len(Rs_parsed) != len(Rs) {
+		t.Fatalf(`ERR: invalid points not kept in the JSON input: %v`, err)
+	}
+
+	stats = recipient.Stats{}
+	matches, err = s.Scan(Rs_parsed, nil, &stats)
+	if err != nil || len(matches) != 1 || matches[0].Index != 4 || stats.NInvalid != 4 {
+		t.Fatalf(`ERR: invalid points not skipped in the JSON input: %+v, %+v (%v)`, matches, stats, err)
+	}
+
+	if _, err := announcement.ParseR(Rs[3]); !errors.Is(err, validation.ErrInvalidEncoding) {
+		t.Fatalf(`ERR: R %q: unexpected error: %v`, Rs[3], err)
+	}
+
+	// Malformed R strings are parse errors, not invalid points
+
+	for _, R := range []string{"zz" + sent_R[2:], "0x" + sent_R[:63], "1.z", "x.1"} {
+
+		if _, err := announcement.ParseR(R); err == nil || validation.IsInvalidPoint(err) {
+			t.Fatalf(`ERR: R %q: unexpected error: %v`, R, err)
+		}
+
+		if _, _, _, err := recipient.ParseRecipientInput(recipientInput([]string{R, sent_R})); err == nil {
+			t.Fatalf(`ERR: R %q: malformed JSON input accepted`, R)
+		}
+
+		err := s.ScanStream(recipient.NewNDJSONReader(strings.NewReader(ndjson([]string{sent_R, R}))), func(recipient.Match) error { return nil }, nil)
+		if err == nil || validation.IsInvalidPoint(err) || !strings.Contains(err.Error(), "line 2") {
+			t.Fatalf(`ERR: R %q: malformed stream accepted: %v`, R, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/gen_example"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+	"ecpdksap-go/view_tags"
+)
+
+func Test_ViewTagSchemes(t *testing.T) {
+
+	expected := map[string]int{"none": 0, "v0-1byte": 1, "v0-2bytes": 2, "v1-1byte": 1, "v0-11nibbles": 6}
+
+	if len(view_tags.List()) != len(expected) {
+		t.Fatalf(`ERR: unexpected registered schemes: %v`, view_tags.List())
+	}
+
+	_, sharedPoint, _ := utils.BN254_GenG1KeyPair()
+
+	for name, length := range expected {
+
+		scheme, err := view_tags.Get(name)
+		if err != nil || scheme.Name() != name || scheme.Length() != length {
+			t.Fatalf(`ERR: %s: %v`, name, err)
+		}
+
+		viewTag := scheme.Compute(&sharedPoint)
+		if _, err := hex.DecodeString(viewTag); err != nil || len(viewTag) != 2*length {
+			t.Fatalf(`ERR: %s: invalid view tag %q`, name, viewTag)
+		}
+
+		if !scheme.Compare(viewTag, strings.ToUpper(viewTag)) || !scheme.Compare(viewTag, viewTag+"ff") {
+			t.Fatalf(`ERR: %s: view tag not matched`, name)
+		}
+
+		if length != 0 && (scheme.Compare(viewTag, viewTag[:len(viewTag)-1]) || scheme.Compare(viewTag, "") || scheme.Compare(viewTag, strings.Repeat("z", len(viewTag)))) {
+			t.Fatalf(`ERR: %s: wrong view tag matched`, name)
+		}
+
+		bc, err := view_tags.GetBytesComputer(name)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, name, err)
+		}
+
+		viewTag_asBytes := make([]byte, length)
+		bc.ComputeBytes(&sharedPoint, viewTag_asBytes)
+		if hex.EncodeToString(viewTag_asBytes) != viewTag {
+			t.Fatalf(`ERR: %s: raw view tag %x differs from %s`, name, viewTag_asBytes, viewTag)
+		}
+	}
+
+	//note: the X coordinate view tag only reads X (the benchmarks compute Y for the matches only)
+	xOnly := BN254.G1Affine{X: sharedPoint.X}
+	viewTag_asBytes := make([]byte, 1)
+	bc, _ := view_tags.GetBytesComputer("v1-1byte")
+	bc.ComputeBytes(&xOnly, viewTag_asBytes)
+	if X := sharedPoint.X.Bytes(); viewTag_asBytes[0] != X[0] {
+		t.Fatalf(`ERR: unexpected X coordinate view tag %x`, viewTag_asBytes)
+	}
+
+	// 11 nibbles: the first 44 bits of the hash
+	scheme, _ := view_tags.Get("v0-11nibbles")
+	hash := hex.EncodeToString(utils.BN254_HashG1Point(&sharedPoint))
+	if viewTag := scheme.Compute(&sharedPoint); viewTag != "0"+hash[:11] {
+		t.Fatalf(`ERR: unexpected 11-nibble view tag %s for hash %s`, viewTag, hash)
+	}
+
+	if _, err := view_tags.Get("v0-3bytes"); err == nil {
+		t.Fatalf(`ERR: unknown view tag version accepted`)
+	}
+}
+
+func Test_ViewTag_11Nibbles(t *testing.T) {
+
+	scheme, _ := view_tags.Get("v0-11nibbles")
+
+	for viewTag, valid := range map[string]bool{"0a1b2c3d4e5f": true, "1a1b2c3d4e5f": false, "a1b2c3d4e5f": false, "0a1b2c3d4e": false} {
+		if err := view_tags.Validate(scheme, viewTag); (err == nil) != valid {
+			t.Fatalf(`ERR: %s: unexpected validation result: %v`, viewTag, err)
+		}
+	}
+
+	// Sender -> announcement encoding -> recipient
+
+	p, _ := versions.Get("v2")
+	keys, _ := p.GenerateMetaAddress()
+
+	r, _, _ := utils.BN254_GenG1KeyPair()
+	sent, err := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-11nibbles"})
+	if err != nil {
+		t.Fatalf(`ERR: %v`, err)
+	}
+
+	ephemeralPubKey, metadata, err := announcement.Encode(&sent.R, sent.ViewTag, "v0-11nibbles")
+	if err != nil || len(metadata) != 6 {
+		t.Fatalf(`ERR: invalid metadata %x: %v`, metadata, err)
+	}
+
+	R, viewTag, err := announcement.Decode(ephemeralPubKey, append(metadata, 0xaa, 0xbb), "v0-11nibbles")
+	if err != nil || viewTag != sent.ViewTag {
+		t.Fatalf(`ERR: announcement not decoded: %v`, err)
+	}
+
+	sampleSize := 1_000
+	targetIdx := 500
+
+	var Rs []BN254.G1Affine
+	var viewTags []string
+	for i := 0; i < sampleSize; i++ {
+		if i == targetIdx {
+			Rs = append(Rs, R)
+			viewTags = append(viewTags, viewTag)
+			continue
+		}
+
+		_, other, _ := utils.BN254_GenG1KeyPair()
+		Rs = append(Rs, other)
+		viewTags = append(viewTags, "000000000000")
+	}
+
+	s, _ := recipient.NewScanner(keys, "v0-11nibbles")
+
+	var stats recipient.Stats
+	matches, err := s.Scan(Rs, viewTags, &stats)
+	if err != nil || len(matches) != 1 || matches[0].Index != targetIdx || matches[0].Address != sent.StealthAddress {
+		t.Fatalf(`ERR: unexpected matches: %+v (%v)`, matches, err)
+	}
+
+	// Example generation
+
+	_, recipientParams := gen_example.GenerateExample("v2", "v0-11nibbles", "20")
+	jsonBytes, _ := json.Marshal(recipientParams)
+
+	output, stats, err := recipient.ScanFromJSON(string(jsonBytes))
+	if err != nil || stats.NFullRuns != 1 || len(output.Matches) != 1 || output.Matches[0].Index != 19 {
+		t.Fatalf(`ERR: example not matched: %+v (%v)`, output, err)
+	}
+}
+
+// Cross-role test vectors: the view tag depends only on the shared point r·V == v·R
+// note: r == 2 gives a shared point with a leading zero nibble in X (0x0fc4...)
+func Test_ViewTag_Vectors(t *testing.T) {
+
+	k, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000007")
+	v, _ := hex.DecodeString("1dd06ca07978ccae708ae87f9da237570a928e1597addb675a3d65997da5fbf9")
+	r, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000002")
+
+	expected := map[string]string{
+		"none":         "",
+		"v0-1byte":     "af",
+		"v0-2bytes":    "af84",
+		"v1-1byte":     "0f",
+		"v0-11nibbles": "0af847f22903",
+	}
+
+	var PK_r BN254_fr.Element
+	PK_r.SetBytes(r)
+
+	for _, version := range []string{"v0", "v1", "v2"} {
+
+		p, _ := versions.Get(version)
+		keys, err := p.KeysFromPrivate(k, v)
+		if err != nil {
+			t.Fatalf(`ERR: %s: %v`, version, err)
+		}
+
+		checker, _ := p.NewChecker(&keys)
+
+		for viewTagVersion, viewTag := range expected {
+
+			sent, err := sender.Send(sender.SendRequest{PK_r: PK_r, Meta: keys.Meta, ViewTagVersion: viewTagVersion})
+			if err != nil || sent.ViewTag != viewTag {
+				t.Fatalf(`ERR: %s, %s: sender computed view tag %q, expected %q (%v)`, version, viewTagVersion, sent.ViewTag, viewTag, err)
+			}
+
+			scheme, _ := view_tags.Get(viewTagVersion)
+			vR := checker.SharedPoint(&sent.R)
+
+			if computed := scheme.Compute(&vR); computed != viewTag || !scheme.Compare(computed, sent.ViewTag) {
+				t.Fatalf(`ERR: %s, %s: recipient computed view tag %q, expected %q`, version, viewTagVersion, computed, viewTag)
+			}
+		}
+	}
+}
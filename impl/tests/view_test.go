@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"ecpdksap-go/announcement"
+	"ecpdksap-go/keystore"
+	"ecpdksap-go/meta_address"
+	"ecpdksap-go/recipient"
+	"ecpdksap-go/sender"
+	"ecpdksap-go/utils"
+	"ecpdksap-go/versions"
+)
+
+func Test_ViewScanFromJSON(t *testing.T) {
+
+	t.Setenv(keystore.PassphraseEnvVar, "pass")
+	dir := t.TempDir()
+
+	for _, version := range []string{"v0", "v1", "v2"} {
+
+		p, _ := versions.Get(version)
+		keys, _ := p.GenerateMetaAddress()
+
+		r, _, _ := utils.BN254_GenG1KeyPair()
+		sent, _ := sender.Send(sender.SendRequest{PK_r: r, Meta: keys.Meta, ViewTagVersion: "v0-1byte"})
+
+		_, foreign, _ := utils.BN254_GenG1KeyPair()
+		Rs := []string{hex.EncodeToString(announcement.EncodeR(&foreign)), hex.EncodeToString(announcement.EncodeR(&sent.R))}
+
+		//note: a foreign view tag never matching the recipient's
+		vR := utils.BN254_MulG1PointandElement(&foreign, &keys.PK_v)
+		foreignViewTag := "00"
+		if utils.BN254_G1PointToViewTag(&vR, 1) == foreignViewTag {
+			foreignViewTag = "01"
+		}
+		viewTags := []string{foreignViewTag, sent.ViewTag}
+
+		metaAddress, _ := meta_address.EncodeString(&keys.Meta)
+
+		var K string
+		if version == "v2" {
+			K = keys.Meta.K_SECP256k1.X.String() + "." + keys.Meta.K_SECP256k1.Y.String()
+		} else {
+			K = utils.BN254_G2PointToString(&keys.Meta.K_G2)
+		}
+
+		ksPath := filepath.Join(dir, version+"-watch-only.json")
+		ks, _ := keystore.EncryptWatchOnly(&keys, "pass", keystore.LightScryptParams)
+		keystore.Store(ksPath, ks)
+
+		v := hex.EncodeToString(keys.PK_v.Marshal())
+
+		inputs := map[string]recipient.RecipientInputData{
+			"meta address":        {PK_v: v, MetaAddress: metaAddress},
+			"K":                   {PK_v: v, K: K, Version: version},
+			"watch-only keystore": {Keystore: ksPath},
+		}
+
+		for name, input := range inputs {
+
+			input.Rs = Rs
+			input.ViewTags = viewTags
+			input.ViewTagVersion = "v0-1byte"
+
+			jsonBytes, _ := json.Marshal(input)
+			output, _, err := recipient.ViewScanFromJSON(string(jsonBytes))
+			if err != nil {
+				t.Fatalf(`ERR: %s, %s: %v`, version, name, err)
+			}
+
+			if len(output.Matches) != 1 {
+				t.Fatalf(`ERR: %s, %s: unexpected matches: %+v`, version, name, output.Matches)
+			}
+
+			m := output.Matches[0]
+			if m.Index != 1 || m.StealthPubKey != hex.EncodeToString(sent.StealthPubKey) || m.Address != sent.StealthAddress {
+				t.Fatalf(`ERR: %s, %s: viewer and sender calculated different stealth info !!!`, version, name)
+			}
+
+			if m.SpendingKey != "" {
+				t.Fatalf(`ERR: %s, %s: watch-only scan returned the stealth private key !!!`, version, name)
+			}
+		}
+
+		// Invalid inputs
+
+		other, _ := p.GenerateMetaAddress()
+
+		invalid := map[string]recipient.RecipientInputData{
+			"private spending key": {PK_k: hex.EncodeToString(keys.PK_v.Marshal()), PK_v: v, MetaAddress: metaAddress},
+			"v not matching V":     {PK_v: hex.EncodeToString(other.PK_v.Marshal()), MetaAddress: metaAddress},
+			"version mismatch":     {PK_v: v, MetaAddress: metaAddress, Version: "v9"},
+			"missing K":            {PK_v: v, Version: version},
+		}
+
+		for name, input := range invalid {
+			jsonBytes, _ := json.Marshal(input)
+			if _, _, err := recipient.ViewScanFromJSON(string(jsonBytes)); err == nil {
+				t.Fatalf(`ERR: %s, %s: invalid input accepted !!!`, version, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package utils
+
+// Ethereum encodings shared by the announcement calldata, the event logs, the JSON-RPC client and the sweep transactions
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Keccak256 is the (legacy, pre-SHA-3 padding) keccak256 of the concatenated inputs
+func Keccak256(in ...[]byte) []byte {
+
+	hash := sha3.NewLegacyKeccak256()
+	for _, b := range in {
+		hash.Write(b)
+	}
+
+	return hash.Sum(nil)
+}
+
+// ParseQuantity parses the hex encoded JSON-RPC quantity ("0x1b4")
+func ParseQuantity(in string) (uint64, error) {
+
+	if !strings.HasPrefix(in, "0x") || len(in) == 2 {
+		return 0, fmt.Errorf("quantity %q is not a 0x prefixed hex string", in)
+	}
+
+	return strconv.ParseUint(in[2:], 16, 64)
+}
+
+// ParseBigQuantity parses the JSON-RPC quantity not fitting into uint64 (e.g. a balance in wei)
+func ParseBigQuantity(in string) (*big.Int, error) {
+
+	n, ok := new(big.Int).SetString(in, 0)
+	if !ok || len(in) < 3 || in[:2] != "0x" || n.Sign() < 0 {
+		return nil, fmt.Errorf("quantity %q is not a 0x prefixed hex string", in)
+	}
+
+	return n, nil
+}
+
+// FormatQuantity encodes the JSON-RPC quantity (see: `ParseQuantity`)
+func FormatQuantity(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+// DecodeWord decodes the (optionally 0x prefixed) hex encoded 32-byte word, e.g. a log topic
+func DecodeWord(in string) ([]byte, error) {
+
+	word, err := hex.DecodeString(strings.TrimPrefix(in, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(word) != 32 {
+		return nil, fmt.Errorf("must be 32 bytes long, got %d", len(word))
+	}
+
+	return word, nil
+}
+
+// UintWord is the big-endian 32-byte word of the integer
+func UintWord(n uint64) []byte {
+
+	var word [32]byte
+	for i := 0; i < 8; i++ {
+		word[31-i] = byte(n >> (8 * i))
+	}
+
+	return word[:]
+}
+
+// AddressWord left-pads the 20-byte address ("0x" followed by 40 hex characters)
+func AddressWord(address string) (word [32]byte, err error) {
+
+	if !strings.HasPrefix(address, "0x") || len(address) != 42 {
+		return word, fmt.Errorf("address %q must be 0x followed by 40 hex characters", address)
+	}
+
+	if _, err = hex.Decode(word[12:], []byte(address[2:])); err != nil {
+		return word, fmt.Errorf("address %q is not a hex string: %w", address, err)
+	}
+
+	return word, nil
+}
+
+// WordToAddress is the inverse of `AddressWord`, the 12 padding bytes must be zero
+func WordToAddress(word []byte) (string, error) {
+
+	if len(word) != 32 {
+		return "", fmt.Errorf("address word must be 32 bytes long, got %d", len(word))
+	}
+
+	for _, b := range word[:12] {
+		if b != 0 {
+			return "", fmt.Errorf("address word 0x%x has non-zero padding", word)
+		}
+	}
+
+	return "0x" + hex.EncodeToString(word[12:]), nil
+}
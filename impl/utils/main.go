@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -8,9 +9,13 @@ import (
 	"strings"
 
 	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	BN254_fp "github.com/consensys/gnark-crypto/ecc/bn254/fp"
 	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
+	SECP256K1_fp "github.com/consensys/gnark-crypto/ecc/secp256k1/fp"
 	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+
+	"ecpdksap-go/validation"
 )
 
 func SECP256k1_MulG1PointandElement(pt *SECP256K1.G1Affine, el *SECP256K1_fr.Element) (res SECP256K1.G1Affine) {
@@ -41,6 +46,50 @@ func SECP256k_Gen1G1KeyPair() (privKey SECP256K1_fr.Element, pubKey SECP256K1.G1
 	return privKey, pubKey
 }
 
+// SECP256k1_CompressG1Point returns the 33-byte SEC1 compressed form: 0x02 | 0x03 (Y parity) followed by X
+func SECP256k1_CompressG1Point(pt *SECP256K1.G1Affine) (res [33]byte) {
+
+	res[0] = 0x02
+	if pt.Y.Bytes()[SECP256K1_fp.Bytes-1]&1 == 1 {
+		res[0] = 0x03
+	}
+
+	X_asBytes := pt.X.Bytes()
+	copy(res[1:], X_asBytes[:])
+
+	return res
+}
+
+// SECP256k1_DecompressG1Point parses the 33-byte SEC1 compressed form (see: `SECP256k1_CompressG1Point`)
+func SECP256k1_DecompressG1Point(buf []byte) (pt SECP256K1.G1Affine, err error) {
+
+	if len(buf) != 33 {
+		return pt, fmt.Errorf("compressed SECP256k1 point must be 33 bytes long, got %d", len(buf))
+	}
+	if buf[0] != 0x02 && buf[0] != 0x03 {
+		return pt, fmt.Errorf("invalid compressed SECP256k1 point prefix 0x%02x", buf[0])
+	}
+
+	if err = pt.X.SetBytesCanonical(buf[1:]); err != nil {
+		return pt, fmt.Errorf("invalid X coordinate: %w", err)
+	}
+
+	// Y^2 = X^3 + 7
+	var Y2, seven SECP256K1_fp.Element
+	seven.SetUint64(7)
+	Y2.Square(&pt.X).Mul(&Y2, &pt.X).Add(&Y2, &seven)
+
+	if pt.Y.Sqrt(&Y2) == nil {
+		return pt, &validation.InvalidPointError{Group: validation.Group_SECP256k1_G1, Err: validation.ErrNotOnCurve}
+	}
+
+	if pt.Y.Bytes()[SECP256K1_fp.Bytes-1]&1 != buf[0]&1 {
+		pt.Y.Neg(&pt.Y)
+	}
+
+	return pt, nil
+}
+
 func BN254_GenG1KeyPair() (privKey BN254_fr.Element, pubKey BN254.G1Affine, _err error) {
 
 	_, err := privKey.SetRandom()
@@ -105,6 +154,52 @@ func BN254_MulG1PointandElement(pt *BN254.G1Affine, el *BN254_fr.Element) (res B
 	return *res.ScalarMultiplication(pt, &el_asBigInt)
 }
 
+// BN254_FixedScalar holds the GLV decomposition of a scalar that is multiplied with many G1 points
+type BN254_FixedScalar struct {
+	neg                bool
+	k1, k2             *big.Int
+	tableElementNeeded int
+	hiWordIndex        int
+	useMatrix          bool
+}
+
+func BN254_PrecomputeFixedScalar(el *BN254_fr.Element) (res BN254_FixedScalar) {
+
+	res.neg, res.k1, res.k2, res.tableElementNeeded, res.hiWordIndex, res.useMatrix = BN254.PrecomputationForFixedScalarMultiplication(el.BigInt(new(big.Int)))
+
+	return res
+}
+
+// MulG1Point computes el·pt (safe for concurrent use)
+func (fs *BN254_FixedScalar) MulG1Point(pt *BN254.G1Affine) (res BN254.G1Affine) {
+
+	var table [15]BN254.G1Jac
+	var pt_asJac, res_asJac BN254.G1Jac
+
+	res_asJac.FixedScalarMultiplication(pt_asJac.FromAffine(pt), &table, fs.neg, fs.k1, fs.k2, fs.tableElementNeeded, fs.hiWordIndex, fs.useMatrix)
+
+	return *res.FromJacobian(&res_asJac)
+}
+
+// BN254_FixedG2Point holds the precomputed Miller loop lines of a G2 point that is paired with many G1 points
+type BN254_FixedG2Point struct {
+	lines [2][66]BN254.LineEvaluationAff
+}
+
+func BN254_PrecomputeFixedG2Point(pt *BN254.G2Affine) (res BN254_FixedG2Point) {
+
+	res.lines = BN254.PrecomputeLines(*pt)
+
+	return res
+}
+
+// PairG1Point computes e(pt, Q) (safe for concurrent use)
+func (fq *BN254_FixedG2Point) PairG1Point(pt *BN254.G1Affine) (BN254.GT, error) {
+
+	//note: copy, the lines are modified in place
+	return BN254.PairFixedQ([]BN254.G1Affine{*pt}, [][2][66]BN254.LineEvaluationAff{fq.lines})
+}
+
 func BN254_G1PointToViewTag(pt *BN254.G1Affine, len uint) (viewTag string) {
 
 	return hex.EncodeToString(BN254_HashG1Point(pt))[:2*len]
@@ -114,14 +209,17 @@ func BN254_G1JacPointToViewTag(pt *BN254.G1Jac, len uint) (viewTag string) {
 	return hex.EncodeToString(BN254_HashG1JacPoint(pt))[:2*len]
 }
 
+// note: X is taken as 32 bytes (big-endian, zero-padded), `X.Text(16)` would drop its leading zero nibbles
 func BN254_G1PointXCoordToViewTag(pt *BN254.G1Affine, len uint) (viewTag string) {
 
-	return pt.X.Text(16)[:2*len]
+	X := pt.X.Bytes()
+	return hex.EncodeToString(X[:len])
 }
 
 func BN254_G1JacPointXCoordToViewTag(pt *BN254.G1Jac, len uint) (viewTag string) {
 
-	return pt.X.Text(16)[:2*len]
+	var pt_asAffine BN254.G1Affine
+	return BN254_G1PointXCoordToViewTag(pt_asAffine.FromJacobian(pt), len)
 }
 
 func BN254_HashG1Point(pt *BN254.G1Affine) []byte {
@@ -134,71 +232,116 @@ func BN254_HashG1Point(pt *BN254.G1Affine) []byte {
 	return hash
 }
 
+// note: hashes the affine X || Y, so that the view tags of the Jacobian and affine points match
 func BN254_HashG1JacPoint(pt *BN254.G1Jac) []byte {
-	hasher := sha256.New()
-	// tmp := pt.X.Bytes()
-	// hasher.Write(tmp[:])
-	// tmp = pt.Y.Bytes()
-	// hasher.Write(tmp[:])
-	// tmp = pt.Z.Bytes()
-	// hasher.Write(tmp[:])
 
 	var pt_asAffine BN254.G1Affine
-	bytes := pt_asAffine.FromJacobian(pt).Bytes()
-	hasher.Write(bytes[:])
+	return BN254_HashG1Point(pt_asAffine.FromJacobian(pt))
+}
 
-	// hasher.Write(pt.X.Marshal())
-	// hasher.Write(pt.Y.Marshal())
-	// hasher.Write(pt.Z.Marshal())
+func Hash(input []byte) []byte {
+	hasher := sha256.New()
+	hasher.Write(input)     // Hash the input
 	hash := hasher.Sum(nil) // Finalize the hash and return the result
 	return hash
 }
 
-func ComputeViewTag(viewTagVersion string, pt *BN254.G1Affine) (viewTag string) {
+func BN254_G1PointFromXY(in string) (pt BN254.G1Affine, err error) {
 
-	if viewTagVersion == "none" {
-		viewTag = ""
-	} else if viewTagVersion == "v0-1byte" {
-		viewTag = BN254_G1PointToViewTag(pt, 1)
+	if strings.IndexByte(in, '.') == -1 {
+		return pt, fmt.Errorf("point %q is not in the `X.Y` format", in)
+	}
 
-	} else if viewTagVersion == "v0-2bytes" {
-		viewTag = BN254_G1PointToViewTag(pt, 2)
+	X, Y := UnpackXY(in)
 
-	} else if viewTagVersion == "v1-1byte" {
-		viewTag = BN254_G1PointXCoordToViewTag(pt, 1)
+	if _, err = pt.X.SetString(X); err != nil {
+		return pt, fmt.Errorf("invalid X coordinate: %w", err)
+	}
+	if _, err = pt.Y.SetString(Y); err != nil {
+		return pt, fmt.Errorf("invalid Y coordinate: %w", err)
 	}
 
-	return viewTag
+	return pt, validation.BN254_G1Point(&pt)
 }
 
-func ComputeViewTagFromJac(viewTagVersion string, pt *BN254.G1Jac) (viewTag string) {
+// SECP256k1_G1PointFromXY parses the "XAffineCoord.YAffineCoord" (decimal) representation of a SECP256k1 point
+func SECP256k1_G1PointFromXY(in string) (pt SECP256K1.G1Affine, err error) {
 
-	var ptAff BN254.G1Affine
-	ptAff.FromJacobian(pt)
-	return ComputeViewTag(viewTagVersion, &ptAff)
+	if strings.IndexByte(in, '.') == -1 {
+		return pt, fmt.Errorf("point %q is not in the `X.Y` format", in)
+	}
+
+	X, Y := UnpackXY(in)
+
+	if _, err = pt.X.SetString(X); err != nil {
+		return pt, fmt.Errorf("invalid X coordinate: %w", err)
+	}
+	if _, err = pt.Y.SetString(Y); err != nil {
+		return pt, fmt.Errorf("invalid Y coordinate: %w", err)
+	}
+
+	return pt, validation.SECP256k1_G1Point(&pt)
 }
 
-func Hash(input []byte) []byte {
-	hasher := sha256.New()
-	hasher.Write(input)     // Hash the input
-	hash := hasher.Sum(nil) // Finalize the hash and return the result
-	return hash
+// BN254_G2PointToString encodes the BN254 G2 point as hex of its compressed form (64 bytes, see: `BN254_G2PointFromString`)
+func BN254_G2PointToString(pt *BN254.G2Affine) string {
+
+	pt_asBytes := pt.Bytes()
+	return hex.EncodeToString(pt_asBytes[:])
+}
+
+// BN254_G2PointFromString parses a BN254 G2 point given either as the hex encoded compressed point (64 bytes)
+// or as "X.A0.X.A1.Y.A0.Y.A1" (decimal, X == X.A0 + X.A1·u); the point is validated (see: `validation.BN254_G2Point`)
+func BN254_G2PointFromString(in string) (pt BN254.G2Affine, err error) {
+
+	coords := strings.Split(in, ".")
+	if len(coords) != 4 {
+
+		pt_asBytes, err := hex.DecodeString(strings.TrimPrefix(in, "0x"))
+		if err != nil {
+			return pt, fmt.Errorf("G2 point %q is neither hex encoded nor in the `X.A0.X.A1.Y.A0.Y.A1` format", in)
+		}
+
+		return BN254_G2PointFromBytes(pt_asBytes)
+	}
+
+	for i, c := range []*BN254_fp.Element{&pt.X.A0, &pt.X.A1, &pt.Y.A0, &pt.Y.A1} {
+		if _, err = c.SetString(coords[i]); err != nil {
+			return pt, fmt.Errorf("invalid coordinate %d: %w", i, err)
+		}
+	}
+
+	return pt, validation.BN254_G2Point(&pt)
 }
 
-func GenRandomRsAndViewTags(len int, viewTagVersion string) (Rs []string, VTags []string) {
+// BN254_G1PointFromBytes decodes the compressed BN254 G1 point (32 bytes) and validates it (see: `validation.BN254_G1Point`)
+func BN254_G1PointFromBytes(buf []byte) (pt BN254.G1Affine, err error) {
 
-	for i := 0; i < len; i++ {
-		r, R, _ := BN254_GenG1KeyPair()
+	if len(buf) != BN254.SizeOfG1AffineCompressed {
+		return pt, fmt.Errorf("compressed G1 point must be %d bytes long, got %d", BN254.SizeOfG1AffineCompressed, len(buf))
+	}
 
-		tmp := BN254_MulG1PointandElement(&R, &r)
-		vTag := ComputeViewTag(viewTagVersion, &tmp)
+	//note: the subgroup is checked by the validation (with a typed error)
+	if err = BN254.NewDecoder(bytes.NewReader(buf), BN254.NoSubgroupChecks()).Decode(&pt); err != nil {
+		return pt, &validation.InvalidPointError{Group: validation.Group_BN254_G1, Err: fmt.Errorf("%w: %v", validation.ErrInvalidEncoding, err)}
+	}
 
-		Rs = append(Rs, R.X.String()+"."+R.Y.String())
+	return pt, validation.BN254_G1Point(&pt)
+}
+
+// BN254_G2PointFromBytes decodes the compressed BN254 G2 point (64 bytes) and validates it (see: `validation.BN254_G2Point`)
+func BN254_G2PointFromBytes(buf []byte) (pt BN254.G2Affine, err error) {
+
+	if len(buf) != BN254.SizeOfG2AffineCompressed {
+		return pt, fmt.Errorf("compressed G2 point must be %d bytes long, got %d", BN254.SizeOfG2AffineCompressed, len(buf))
+	}
 
-		VTags = append(VTags, vTag)
+	//note: the subgroup is checked by the validation (with a typed error)
+	if err = BN254.NewDecoder(bytes.NewReader(buf), BN254.NoSubgroupChecks()).Decode(&pt); err != nil {
+		return pt, &validation.InvalidPointError{Group: validation.Group_BN254_G2, Err: fmt.Errorf("%w: %v", validation.ErrInvalidEncoding, err)}
 	}
 
-	return Rs, VTags
+	return pt, validation.BN254_G2Point(&pt)
 }
 
 func UnpackXY(in string) (X string, Y string) {
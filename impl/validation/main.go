@@ -0,0 +1,112 @@
+package validation
+
+// Validation of the received (untrusted) points: the recipient's V and K on the sender's side and
+// every announced R on the recipient's side. Announcements are posted to the public announcer by
+// anyone, so a point off the curve, outside the prime-order subgroup or at infinity must never
+// reach the scalar multiplication / pairing (invalid-curve and small-subgroup inputs).
+
+import (
+	"errors"
+	"fmt"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
+
+	"ecpdksap-go/protocol"
+)
+
+const (
+	Group_BN254_G1     = "BN254 G1"
+	Group_BN254_G2     = "BN254 G2"
+	Group_SECP256k1_G1 = "SECP256k1"
+)
+
+var (
+	ErrInvalidEncoding = errors.New("invalid encoding")
+	ErrNotOnCurve      = errors.New("not on the curve")
+	ErrNotInSubgroup   = errors.New("not in the prime-order subgroup")
+	ErrInfinity        = errors.New("point at infinity")
+)
+
+// InvalidPointError is returned for every rejected point, `Err` is one of the `Err...` reasons above
+type InvalidPointError struct {
+	Group string
+	Err   error
+}
+
+func (e *InvalidPointError) Error() string {
+	return fmt.Sprintf("invalid %s point: %v", e.Group, e.Err)
+}
+
+func (e *InvalidPointError) Unwrap() error {
+	return e.Err
+}
+
+// IsInvalidPoint reports whether the error (chain) contains an `InvalidPointError`
+func IsInvalidPoint(err error) bool {
+	var pointErr *InvalidPointError
+	return errors.As(err, &pointErr)
+}
+
+func BN254_G1Point(pt *BN254.G1Affine) error {
+
+	if pt.IsInfinity() {
+		return &InvalidPointError{Group: Group_BN254_G1, Err: ErrInfinity}
+	}
+	if !pt.IsOnCurve() {
+		return &InvalidPointError{Group: Group_BN254_G1, Err: ErrNotOnCurve}
+	}
+	if !pt.IsInSubGroup() {
+		return &InvalidPointError{Group: Group_BN254_G1, Err: ErrNotInSubgroup}
+	}
+
+	return nil
+}
+
+func BN254_G2Point(pt *BN254.G2Affine) error {
+
+	if pt.IsInfinity() {
+		return &InvalidPointError{Group: Group_BN254_G2, Err: ErrInfinity}
+	}
+	if !pt.IsOnCurve() {
+		return &InvalidPointError{Group: Group_BN254_G2, Err: ErrNotOnCurve}
+	}
+	if !pt.IsInSubGroup() {
+		return &InvalidPointError{Group: Group_BN254_G2, Err: ErrNotInSubgroup}
+	}
+
+	return nil
+}
+
+// note: SECP256k1 has a prime order (cofactor 1), every point on the curve is in the subgroup
+func SECP256k1_G1Point(pt *SECP256K1.G1Affine) error {
+
+	if pt.IsInfinity() {
+		return &InvalidPointError{Group: Group_SECP256k1_G1, Err: ErrInfinity}
+	}
+	if !pt.IsOnCurve() {
+		return &InvalidPointError{Group: Group_SECP256k1_G1, Err: ErrNotOnCurve}
+	}
+
+	return nil
+}
+
+// MetaAddress validates the recipient's public keys: V and K (in the version's spending key group)
+func MetaAddress(meta *protocol.MetaAddress, spendingKeyGroup protocol.SpendingKeyGroup) error {
+
+	if err := BN254_G1Point(&meta.V); err != nil {
+		return fmt.Errorf("invalid public viewing key 'V': %w", err)
+	}
+
+	var err error
+	if spendingKeyGroup == protocol.SpendingKeyGroup_BN254_G2 {
+		err = BN254_G2Point(&meta.K_G2)
+	} else {
+		err = SECP256k1_G1Point(&meta.K_SECP256k1)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid public spending key 'K': %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package versions
+
+import (
+	"fmt"
+	"sort"
+
+	"ecpdksap-go/protocol"
+
+	ecpdksap_v0 "ecpdksap-go/versions/v0"
+	ecpdksap_v1 "ecpdksap-go/versions/v1"
+	ecpdksap_v2 "ecpdksap-go/versions/v2"
+)
+
+var registry = map[string]protocol.Protocol{}
+
+func init() {
+	Register(ecpdksap_v0.Protocol{})
+	Register(ecpdksap_v1.Protocol{})
+	Register(ecpdksap_v2.Protocol{})
+}
+
+// Register makes the protocol version available via `Get`, replacing any previous one with the same version
+func Register(p protocol.Protocol) {
+	registry[p.Version()] = p
+}
+
+// Get returns the protocol implementation for the given version (v0, v1, v2, ...)
+func Get(version string) (protocol.Protocol, error) {
+
+	p, ok := registry[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown protocol version %q, supported: %v", version, List())
+	}
+
+	return p, nil
+}
+
+// List returns all registered protocol versions
+func List() (versions []string) {
+
+	for version := range registry {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	return versions
+}
@@ -0,0 +1,137 @@
+package v0
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/utils"
+)
+
+// Protocol implements `protocol.Protocol` for the version v0
+type Protocol struct{}
+
+func (Protocol) Version() string {
+	return "v0"
+}
+
+func (Protocol) SpendingKeyGroup() protocol.SpendingKeyGroup {
+	return protocol.SpendingKeyGroup_BN254_G2
+}
+
+func (p Protocol) GenerateMetaAddress() (protocol.Keys, error) {
+
+	k, K, err := utils.BN254_GenG2KeyPair()
+	if err != nil {
+		return protocol.Keys{}, err
+	}
+
+	v, V, err := utils.BN254_GenG1KeyPair()
+	if err != nil {
+		return protocol.Keys{}, err
+	}
+
+	return protocol.Keys{
+		PK_k_BN254: k,
+		PK_v:       v,
+		Meta:       protocol.MetaAddress{Version: p.Version(), K_G2: K, V: V},
+	}, nil
+}
+
+func (p Protocol) KeysFromPrivate(kBytes []byte, vBytes []byte) (protocol.Keys, error) {
+
+	var k, v fr.Element
+	if err := k.SetBytesCanonical(kBytes); err != nil {
+		return protocol.Keys{}, fmt.Errorf("invalid private spending key 'k': %w", err)
+	}
+	if err := v.SetBytesCanonical(vBytes); err != nil {
+		return protocol.Keys{}, fmt.Errorf("invalid private viewing key 'v': %w", err)
+	}
+
+	K, _ := utils.BN254_CalcG2PubKey(k)
+	V, _ := utils.BN254_CalcG1PubKey(v)
+
+	return protocol.Keys{
+		PK_k_BN254: k,
+		PK_v:       v,
+		Meta:       protocol.MetaAddress{Version: p.Version(), K_G2: K, V: V},
+	}, nil
+}
+
+func (Protocol) DeriveStealth(r *fr.Element, meta *protocol.MetaAddress) (stealth protocol.Stealth, err error) {
+
+	stealth.R, _ = utils.BN254_CalcG1PubKey(*r)
+	stealth.SharedPoint = utils.BN254_MulG1PointandElement(&meta.V, r)
+
+	stealth.P_GT, err = SenderComputesStealthPubKey(r, &meta.V, &meta.K_G2)
+
+	return stealth, err
+}
+
+func (Protocol) CheckAnnouncement(keys *protocol.Keys, R *bn254.G1Affine, vR *bn254.G1Affine) (stealth protocol.Stealth, err error) {
+
+	if vR == nil {
+		tmp := utils.BN254_MulG1PointandElement(R, &keys.PK_v)
+		vR = &tmp
+	}
+
+	stealth.R = *R
+	stealth.SharedPoint = *vR
+
+	//note: e(R, K)^v == e(v·R, K) (see: `checker.CheckAnnouncement`)
+	stealth.P_GT, err = bn254.Pair([]bn254.G1Affine{*vR}, []bn254.G2Affine{keys.Meta.K_G2})
+	if err != nil {
+		return protocol.Stealth{}, fmt.Errorf("error computing pairing: %w", err)
+	}
+
+	return stealth, nil
+}
+
+// note: e(R, K)^v == e(R, G2)^(k·v), so the private key is the same for all stealth public keys of the recipient
+// (with a different base for every R)
+func (Protocol) DeriveStealthPrivateKey(keys *protocol.Keys, stealth *protocol.Stealth) (*big.Int, error) {
+
+	var kv fr.Element
+	kv.Mul(&keys.PK_k_BN254, &keys.PK_v)
+
+	return kv.BigInt(new(big.Int)), nil
+}
+
+func (Protocol) NewChecker(keys *protocol.Keys) (protocol.Checker, error) {
+
+	return &checker{
+		v: utils.BN254_PrecomputeFixedScalar(&keys.PK_v),
+		K: utils.BN254_PrecomputeFixedG2Point(&keys.Meta.K_G2),
+	}, nil
+}
+
+type checker struct {
+	v utils.BN254_FixedScalar
+	K utils.BN254_FixedG2Point
+}
+
+func (c *checker) SharedPoint(R *bn254.G1Affine) bn254.G1Affine {
+	return c.v.MulG1Point(R)
+}
+
+// note: e(R, K)^v == e(v·R, K), so the (already computed) shared point is paired instead of the exponentiation
+func (c *checker) CheckAnnouncement(R *bn254.G1Affine, vR *bn254.G1Affine) (stealth protocol.Stealth, err error) {
+
+	if vR == nil {
+		tmp := c.SharedPoint(R)
+		vR = &tmp
+	}
+
+	stealth.R = *R
+	stealth.SharedPoint = *vR
+
+	stealth.P_GT, err = c.K.PairG1Point(vR)
+	if err != nil {
+		return protocol.Stealth{}, fmt.Errorf("error computing pairing: %w", err)
+	}
+
+	return stealth, nil
+}
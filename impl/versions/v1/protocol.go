@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	BN254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/utils"
+)
+
+// Protocol implements `protocol.Protocol` for the version v1
+type Protocol struct{}
+
+func (Protocol) Version() string {
+	return "v1"
+}
+
+func (Protocol) SpendingKeyGroup() protocol.SpendingKeyGroup {
+	return protocol.SpendingKeyGroup_BN254_G2
+}
+
+func (p Protocol) GenerateMetaAddress() (protocol.Keys, error) {
+
+	k, K, err := utils.BN254_GenG2KeyPair()
+	if err != nil {
+		return protocol.Keys{}, err
+	}
+
+	v, V, err := utils.BN254_GenG1KeyPair()
+	if err != nil {
+		return protocol.Keys{}, err
+	}
+
+	return protocol.Keys{
+		PK_k_BN254: k,
+		PK_v:       v,
+		Meta:       protocol.MetaAddress{Version: p.Version(), K_G2: K, V: V},
+	}, nil
+}
+
+func (p Protocol) KeysFromPrivate(kBytes []byte, vBytes []byte) (protocol.Keys, error) {
+
+	var k, v BN254_fr.Element
+	if err := k.SetBytesCanonical(kBytes); err != nil {
+		return protocol.Keys{}, fmt.Errorf("invalid private spending key 'k': %w", err)
+	}
+	if err := v.SetBytesCanonical(vBytes); err != nil {
+		return protocol.Keys{}, fmt.Errorf("invalid private viewing key 'v': %w", err)
+	}
+
+	K, _ := utils.BN254_CalcG2PubKey(k)
+	V, _ := utils.BN254_CalcG1PubKey(v)
+
+	return protocol.Keys{
+		PK_k_BN254: k,
+		PK_v:       v,
+		Meta:       protocol.MetaAddress{Version: p.Version(), K_G2: K, V: V},
+	}, nil
+}
+
+func (Protocol) DeriveStealth(r *BN254_fr.Element, meta *protocol.MetaAddress) (stealth protocol.Stealth, err error) {
+
+	stealth.R, _ = utils.BN254_CalcG1PubKey(*r)
+	stealth.SharedPoint = utils.BN254_MulG1PointandElement(&meta.V, r)
+
+	stealth.P_GT, err = SenderComputesStealthPubKey(r, &meta.V, &meta.K_G2)
+
+	return stealth, err
+}
+
+func (Protocol) CheckAnnouncement(keys *protocol.Keys, R *BN254.G1Affine, vR *BN254.G1Affine) (stealth protocol.Stealth, err error) {
+
+	if vR == nil {
+		tmp := utils.BN254_MulG1PointandElement(R, &keys.PK_v)
+		vR = &tmp
+	}
+
+	stealth.R = *R
+	stealth.SharedPoint = *vR
+
+	stealth.P_GT, err = stealthPubKeyFromSharedPoint(&keys.Meta.K_G2, vR)
+
+	return stealth, err
+}
+
+// note: e(hash(v·R)·G1, K) == e(G1, G2)^(hash(v·R)·k)
+func (Protocol) DeriveStealthPrivateKey(keys *protocol.Keys, stealth *protocol.Stealth) (*big.Int, error) {
+
+	vR := stealth.SharedPoint
+	if vR.IsInfinity() {
+		vR = utils.BN254_MulG1PointandElement(&stealth.R, &keys.PK_v)
+	}
+
+	hash := sharedPointHash(&vR)
+
+	var privKey BN254_fr.Element
+	privKey.Mul(&hash, &keys.PK_k_BN254)
+
+	return privKey.BigInt(new(big.Int)), nil
+}
+
+// computes e(hash(v·R)·G1, K) for an already computed shared point v·R
+func stealthPubKeyFromSharedPoint(K *BN254.G2Affine, vR *BN254.G1Affine) (BN254.GT, error) {
+
+	g1Point := hashedSharedPoint(vR)
+
+	P, err := BN254.Pair([]BN254.G1Affine{g1Point}, []BN254.G2Affine{*K})
+	if err != nil {
+		return BN254.GT{}, fmt.Errorf("error computing pairing: %w", err)
+	}
+
+	return P, nil
+}
+
+// computes hash(v·R) (sha256 reduced into BN254 Fr)
+func sharedPointHash(vR *BN254.G1Affine) (hash BN254_fr.Element) {
+
+	hash.SetBytes(utils.BN254_HashG1Point(vR))
+
+	return hash
+}
+
+// computes hash(v·R)·G1
+func hashedSharedPoint(vR *BN254.G1Affine) (g1Point BN254.G1Affine) {
+
+	hash := sharedPointHash(vR)
+	var hash_asBigInt big.Int
+	hash.BigInt(&hash_asBigInt)
+
+	g1Point.ScalarMultiplicationBase(&hash_asBigInt)
+
+	return g1Point
+}
+
+func (Protocol) NewChecker(keys *protocol.Keys) (protocol.Checker, error) {
+
+	return &checker{
+		v: utils.BN254_PrecomputeFixedScalar(&keys.PK_v),
+		K: utils.BN254_PrecomputeFixedG2Point(&keys.Meta.K_G2),
+	}, nil
+}
+
+type checker struct {
+	v utils.BN254_FixedScalar
+	K utils.BN254_FixedG2Point
+}
+
+func (c *checker) SharedPoint(R *BN254.G1Affine) BN254.G1Affine {
+	return c.v.MulG1Point(R)
+}
+
+func (c *checker) CheckAnnouncement(R *BN254.G1Affine, vR *BN254.G1Affine) (stealth protocol.Stealth, err error) {
+
+	if vR == nil {
+		tmp := c.SharedPoint(R)
+		vR = &tmp
+	}
+
+	stealth.R = *R
+	stealth.SharedPoint = *vR
+
+	g1Point := hashedSharedPoint(vR)
+
+	stealth.P_GT, err = c.K.PairG1Point(&g1Point)
+	if err != nil {
+		return protocol.Stealth{}, fmt.Errorf("error computing pairing: %w", err)
+	}
+
+	return stealth, nil
+}
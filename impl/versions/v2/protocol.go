@@ -0,0 +1,156 @@
+package v2
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	SECP256K1 "github.com/consensys/gnark-crypto/ecc/secp256k1"
+	SECP256K1_fr "github.com/consensys/gnark-crypto/ecc/secp256k1/fr"
+
+	"ecpdksap-go/protocol"
+	"ecpdksap-go/utils"
+)
+
+// Protocol implements `protocol.Protocol` for the version v2
+type Protocol struct{}
+
+func (Protocol) Version() string {
+	return "v2"
+}
+
+func (Protocol) SpendingKeyGroup() protocol.SpendingKeyGroup {
+	return protocol.SpendingKeyGroup_SECP256k1
+}
+
+func (p Protocol) GenerateMetaAddress() (protocol.Keys, error) {
+
+	k, K := utils.SECP256k_Gen1G1KeyPair()
+
+	v, V, err := utils.BN254_GenG1KeyPair()
+	if err != nil {
+		return protocol.Keys{}, err
+	}
+
+	return protocol.Keys{
+		PK_k_SECP256k1: k,
+		PK_v:           v,
+		Meta:           protocol.MetaAddress{Version: p.Version(), K_SECP256k1: K, V: V},
+	}, nil
+}
+
+func (p Protocol) KeysFromPrivate(kBytes []byte, vBytes []byte) (protocol.Keys, error) {
+
+	var k SECP256K1_fr.Element
+	if err := k.SetBytesCanonical(kBytes); err != nil {
+		return protocol.Keys{}, fmt.Errorf("invalid private spending key 'k': %w", err)
+	}
+
+	var v fr.Element
+	if err := v.SetBytesCanonical(vBytes); err != nil {
+		return protocol.Keys{}, fmt.Errorf("invalid private viewing key 'v': %w", err)
+	}
+
+	var K SECP256K1.G1Affine
+	K.ScalarMultiplicationBase(k.BigInt(new(big.Int)))
+
+	V, _ := utils.BN254_CalcG1PubKey(v)
+
+	return protocol.Keys{
+		PK_k_SECP256k1: k,
+		PK_v:           v,
+		Meta:           protocol.MetaAddress{Version: p.Version(), K_SECP256k1: K, V: V},
+	}, nil
+}
+
+func (Protocol) DeriveStealth(r *fr.Element, meta *protocol.MetaAddress) (stealth protocol.Stealth, err error) {
+
+	stealth.R, _ = utils.BN254_CalcG1PubKey(*r)
+	stealth.SharedPoint = utils.BN254_MulG1PointandElement(&meta.V, r)
+
+	stealth.P_GT = SenderComputesSharedSecret(r, &meta.V, &meta.K_SECP256k1)
+
+	b := Compute_b_asElement(&stealth.P_GT)
+	stealth.P_SECP256k1 = utils.SECP256k1_MulG1PointandElement(&meta.K_SECP256k1, &b)
+	stealth.Address = ComputeEthAddress(&stealth.P_SECP256k1)
+
+	return stealth, nil
+}
+
+func (Protocol) CheckAnnouncement(keys *protocol.Keys, R *bn254.G1Affine, vR *bn254.G1Affine) (stealth protocol.Stealth, err error) {
+
+	if vR == nil {
+		tmp := utils.BN254_MulG1PointandElement(R, &keys.PK_v)
+		vR = &tmp
+	}
+
+	stealth.R = *R
+	stealth.SharedPoint = *vR
+
+	_, _, _, G2_BN254 := bn254.Generators()
+
+	stealth.P_GT, err = bn254.Pair([]bn254.G1Affine{*vR}, []bn254.G2Affine{G2_BN254})
+	if err != nil {
+		return protocol.Stealth{}, fmt.Errorf("error computing pairing: %w", err)
+	}
+
+	b := Compute_b_asElement(&stealth.P_GT)
+	stealth.P_SECP256k1 = utils.SECP256k1_MulG1PointandElement(&keys.Meta.K_SECP256k1, &b)
+	stealth.Address = ComputeEthAddress(&stealth.P_SECP256k1)
+
+	return stealth, nil
+}
+
+func (Protocol) DeriveStealthPrivateKey(keys *protocol.Keys, stealth *protocol.Stealth) (*big.Int, error) {
+
+	b := Compute_b_asElement(&stealth.P_GT)
+
+	var kb SECP256K1_fr.Element
+	kb.Mul(&keys.PK_k_SECP256k1, &b)
+
+	return kb.BigInt(new(big.Int)), nil
+}
+
+func (Protocol) NewChecker(keys *protocol.Keys) (protocol.Checker, error) {
+
+	_, _, _, G2_BN254 := bn254.Generators()
+
+	return &checker{
+		K:  keys.Meta.K_SECP256k1,
+		v:  utils.BN254_PrecomputeFixedScalar(&keys.PK_v),
+		G2: utils.BN254_PrecomputeFixedG2Point(&G2_BN254),
+	}, nil
+}
+
+type checker struct {
+	K  SECP256K1.G1Affine
+	v  utils.BN254_FixedScalar
+	G2 utils.BN254_FixedG2Point
+}
+
+func (c *checker) SharedPoint(R *bn254.G1Affine) bn254.G1Affine {
+	return c.v.MulG1Point(R)
+}
+
+func (c *checker) CheckAnnouncement(R *bn254.G1Affine, vR *bn254.G1Affine) (stealth protocol.Stealth, err error) {
+
+	if vR == nil {
+		tmp := c.SharedPoint(R)
+		vR = &tmp
+	}
+
+	stealth.R = *R
+	stealth.SharedPoint = *vR
+
+	stealth.P_GT, err = c.G2.PairG1Point(vR)
+	if err != nil {
+		return protocol.Stealth{}, fmt.Errorf("error computing pairing: %w", err)
+	}
+
+	b := Compute_b_asElement(&stealth.P_GT)
+	stealth.P_SECP256k1 = utils.SECP256k1_MulG1PointandElement(&c.K, &b)
+	stealth.Address = ComputeEthAddress(&stealth.P_SECP256k1)
+
+	return stealth, nil
+}
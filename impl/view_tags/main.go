@@ -0,0 +1,117 @@
+package view_tags
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+)
+
+// ViewTagScheme is implemented by every view tag variant
+//
+// The view tag is computed from the shared point (r·V == v·R) by the sender, announced in the
+// beginning of the metadata and recomputed by the recipient to skip the (pairing-based) remaining
+// calculation for the announcements that are not theirs.
+//
+// The shared point is the same for all protocol versions (v0, v1, v2): the affine BN254 G1 point
+// r·V (sender) == v·R (recipient), its coordinates X and Y are encoded as 32 bytes big-endian.
+type ViewTagScheme interface {
+
+	// Name returns the view tag version identifier (e.g. v0-1byte)
+	Name() string
+
+	// Length returns the number of bytes the view tag takes in the announcement's metadata
+	Length() int
+
+	// Compute returns the hex encoded view tag (2·Length() characters) for the shared point
+	Compute(sharedPoint *BN254.G1Affine) string
+
+	// Compare reports whether the announced (hex encoded) view tag matches the computed one
+	Compare(computed string, announced string) bool
+}
+
+// BytesComputer is implemented by the schemes computing the raw view tag bytes (no hex encoding),
+// e.g. for the hot loops of the benchmarks
+type BytesComputer interface {
+	ViewTagScheme
+
+	// ComputeBytes writes the view tag (Length() bytes) for the shared point into `dst`
+	ComputeBytes(sharedPoint *BN254.G1Affine, dst []byte)
+}
+
+// Validator is implemented by the schemes that restrict the view tag beyond its length
+type Validator interface {
+	Validate(viewTag string) error
+}
+
+// Validate checks that the (hex encoded) view tag is a valid view tag of the scheme
+func Validate(scheme ViewTagScheme, viewTag string) error {
+
+	viewTag_asBytes, err := hex.DecodeString(viewTag)
+	if err != nil {
+		return fmt.Errorf("view tag is not a hex string: %w", err)
+	}
+
+	if len(viewTag_asBytes) != scheme.Length() {
+		return fmt.Errorf("view tag %s must be %d bytes long, got %d", scheme.Name(), scheme.Length(), len(viewTag_asBytes))
+	}
+
+	if v, ok := scheme.(Validator); ok {
+		return v.Validate(viewTag)
+	}
+
+	return nil
+}
+
+var registry = map[string]ViewTagScheme{}
+
+// Register adds the scheme to the registry (replacing the one with the same name)
+func Register(s ViewTagScheme) {
+	registry[s.Name()] = s
+}
+
+// Get returns the scheme for the given view tag version
+func Get(viewTagVersion string) (ViewTagScheme, error) {
+
+	s, ok := registry[viewTagVersion]
+	if !ok {
+		return nil, fmt.Errorf("unknown view tag version %q, supported: %v", viewTagVersion, List())
+	}
+
+	return s, nil
+}
+
+// GetBytesComputer returns the scheme for the given view tag version, if it computes the raw view tag bytes
+func GetBytesComputer(viewTagVersion string) (BytesComputer, error) {
+
+	s, err := Get(viewTagVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	bc, ok := s.(BytesComputer)
+	if !ok {
+		return nil, fmt.Errorf("view tag version %q does not compute the raw view tag bytes", viewTagVersion)
+	}
+
+	return bc, nil
+}
+
+// List returns the names of all registered schemes
+func List() []string {
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// compareHex is the default `Compare`: the announced view tag starts with the computed one (case-insensitive)
+func compareHex(computed string, announced string) bool {
+	return len(announced) >= len(computed) && strings.EqualFold(announced[:len(computed)], computed)
+}
@@ -0,0 +1,122 @@
+package view_tags
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	BN254 "github.com/consensys/gnark-crypto/ecc/bn254"
+
+	"ecpdksap-go/utils"
+)
+
+func init() {
+	Register(None{})
+	Register(Hash{name: "v0-1byte", nBytes: 1})
+	Register(Hash{name: "v0-2bytes", nBytes: 2})
+	Register(XCoord{name: "v1-1byte", nBytes: 1})
+	Register(HashNibbles{name: "v0-11nibbles", nNibbles: 11})
+}
+
+// None: no view tag, every announcement goes through the remaining calculation
+type None struct{}
+
+func (None) Name() string                            { return "none" }
+func (None) Length() int                             { return 0 }
+func (None) Compute(*BN254.G1Affine) string          { return "" }
+func (None) ComputeBytes(*BN254.G1Affine, []byte)    {}
+func (None) Compare(computed, announced string) bool { return true }
+
+// Hash: the first bytes of sha256(X || Y) of the shared point (v0-...)
+type Hash struct {
+	name   string
+	nBytes int
+}
+
+func (s Hash) Name() string { return s.name }
+func (s Hash) Length() int  { return s.nBytes }
+
+func (s Hash) Compute(sharedPoint *BN254.G1Affine) string {
+	return utils.BN254_G1PointToViewTag(sharedPoint, uint(s.nBytes))
+}
+
+func (s Hash) ComputeBytes(sharedPoint *BN254.G1Affine, dst []byte) {
+	copy(dst[:s.nBytes], utils.BN254_HashG1Point(sharedPoint))
+}
+
+func (Hash) Compare(computed, announced string) bool {
+	return compareHex(computed, announced)
+}
+
+// XCoord: the first bytes of the shared point's X coordinate, no hashing needed (v1-...)
+type XCoord struct {
+	name   string
+	nBytes int
+}
+
+func (s XCoord) Name() string { return s.name }
+func (s XCoord) Length() int  { return s.nBytes }
+
+func (s XCoord) Compute(sharedPoint *BN254.G1Affine) string {
+	return utils.BN254_G1PointXCoordToViewTag(sharedPoint, uint(s.nBytes))
+}
+
+// note: only X is read, so the Y coordinate can be left to the matching announcements (see: `G1Affine.FromJacobianCoordX`)
+func (s XCoord) ComputeBytes(sharedPoint *BN254.G1Affine, dst []byte) {
+	X := sharedPoint.X.Bytes()
+	copy(dst[:s.nBytes], X[:])
+}
+
+func (XCoord) Compare(computed, announced string) bool {
+	return compareHex(computed, announced)
+}
+
+// HashNibbles: the first `nNibbles` nibbles (4·nNibbles bits) of sha256(X || Y) of the shared point,
+// right-aligned in ceil(nNibbles/2) bytes (i.e. with a zero nibble in front for an odd `nNibbles`)
+type HashNibbles struct {
+	name     string
+	nNibbles int
+}
+
+func (s HashNibbles) Name() string { return s.name }
+func (s HashNibbles) Length() int  { return (s.nNibbles + 1) / 2 }
+
+func (s HashNibbles) Compute(sharedPoint *BN254.G1Affine) string {
+
+	tag := make([]byte, s.Length())
+	s.ComputeBytes(sharedPoint, tag)
+
+	return hex.EncodeToString(tag)
+}
+
+func (s HashNibbles) ComputeBytes(sharedPoint *BN254.G1Affine, dst []byte) {
+
+	var prefix [8]byte
+	copy(prefix[:], utils.BN254_HashG1Point(sharedPoint)[:8])
+
+	tag := binary.BigEndian.Uint64(prefix[:]) >> (64 - 4*s.nNibbles)
+
+	var tag_asBytes [8]byte
+	binary.BigEndian.PutUint64(tag_asBytes[:], tag)
+
+	copy(dst[:s.Length()], tag_asBytes[8-s.Length():])
+}
+
+func (HashNibbles) Compare(computed, announced string) bool {
+	return compareHex(computed, announced)
+}
+
+// Validate checks that the padding bits (in front of the nibbles) are zero
+func (s HashNibbles) Validate(viewTag string) error {
+
+	viewTag_asBytes, err := hex.DecodeString(viewTag)
+	if err != nil || len(viewTag_asBytes) != s.Length() {
+		return fmt.Errorf("view tag %s must be %d bytes long", s.name, s.Length())
+	}
+
+	if nPaddingBits := 8*s.Length() - 4*s.nNibbles; viewTag_asBytes[0]>>(8-nPaddingBits) != 0 {
+		return fmt.Errorf("view tag %s must start with %d zero bits", s.name, nPaddingBits)
+	}
+
+	return nil
+}
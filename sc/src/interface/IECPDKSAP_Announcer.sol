@@ -3,6 +3,8 @@ pragma solidity ^0.8.20;
 
 /// @notice Interface for calling the `ECPDKSAP_Announcer` contract which emits
 ///         information about eth transfers (Sender Ephermeral public key and view tag)
+/// @dev `_R` is a compressed BN254 G1 point (32 bytes) and `_viewTag` holds the view tag bytes,
+///      emitted as the first bytes of the `metadata`. See `impl/announcement`.
 interface IECPDKSAP_Announcer {
   /// @notice Sends ether using the announcer contract as a proxy
   /// @param _stealthAddress Destination for the ether (Recipient's stealth address)
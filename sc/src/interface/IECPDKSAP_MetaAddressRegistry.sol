@@ -3,6 +3,9 @@ pragma solidity ^0.8.20;
 
 /// @notice Interface for calling the `ECPDKSAP_MetaAddressRegistry` contract, which stores
 /// information about used `id`s and corresponding Meta addresses (Spending & Viewing public keys)
+/// @dev Meta addresses are encoded as: schemeId (2 bytes, 3327) | protocol version (1 byte) | K | V,
+///      where K is a compressed BN254 G2 point (64 bytes, v0 & v1) or a SEC1 compressed secp256k1 point
+///      (33 bytes, v2) and V is a compressed BN254 G1 point (32 bytes). See `impl/meta_address`.
 interface IECPDKSAP_MetaAddressRegistry {
   /// @notice Registers an `_id` to the underlying meta address
   /// @param _id Identifier corresponding to the raw bytes `_metaAddress`